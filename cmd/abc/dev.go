@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/sats-group/abc/internal/files"
+	"github.com/sats-group/abc/pkg/web"
+)
+
+const (
+	reloadPath   = "/abc/reload"
+	reloadScript = `<script>new EventSource("` + reloadPath + `").onmessage = function () { location.reload() }</script>`
+)
+
+// reloader tells connected browsers to reload when the site changes.
+type reloader struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]bool
+}
+
+func dev(args []string) {
+	fs, c := configFlags("dev")
+	parse(fs, args)
+	logErrors(c)
+
+	c.Prod = false
+	w := web.New(c)
+	r := &reloader{clients: map[chan struct{}]bool{}}
+
+	dirs := append([]string{c.Dir}, c.TemplateDirs...)
+
+	if c.Candidate != "" {
+		dirs = append(dirs, c.Candidate)
+	}
+
+	for _, dir := range dirs {
+		watcher, err := files.Watch(dir, func(events []files.Event) {
+			log.Printf("changed: %d files, reloading\n", len(events))
+			r.broadcast()
+		})
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		defer watcher.Close()
+	}
+
+	w.HandlerFunc("get", reloadPath, r.events)
+	w.MiddlewareFunc(r.inject)
+
+	log.Fatalln(w.Serve())
+}
+
+func (re *reloader) broadcast() {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	for ch := range re.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (re *reloader) events(rw http.ResponseWriter, r *http.Request, _ web.Params) {
+	f, ok := rw.(http.Flusher)
+
+	if !ok {
+		http.Error(rw, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := make(chan struct{}, 1)
+
+	re.mu.Lock()
+	re.clients[ch] = true
+	re.mu.Unlock()
+
+	defer func() {
+		re.mu.Lock()
+		delete(re.clients, ch)
+		re.mu.Unlock()
+	}()
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(http.StatusOK)
+	f.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ch:
+			fmt.Fprint(rw, "data: reload\n\n")
+			f.Flush()
+		}
+	}
+}
+
+// inject adds the reload script to HTML responses. Other responses,
+// like streams and websocket upgrades, pass through untouched.
+func (re *reloader) inject(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if r.URL.Path == reloadPath || r.Method != http.MethodGet || r.Header.Get("Upgrade") != "" {
+		next(rw, r)
+		return
+	}
+
+	buf := &bufferWriter{ResponseWriter: rw}
+	next(buf, r)
+
+	if !buf.html {
+		return
+	}
+
+	b := injectScript(buf.body.Bytes())
+	rw.Header().Del("Content-Length")
+	rw.WriteHeader(buf.status)
+
+	if _, err := rw.Write(b); err != nil {
+		log.Println(r.URL.Path, err)
+	}
+}
+
+func injectScript(b []byte) []byte {
+	i := bytes.LastIndex(b, []byte("</body>"))
+
+	if i == -1 {
+		return append(b, reloadScript...)
+	}
+
+	out := append([]byte{}, b[:i]...)
+	out = append(out, reloadScript...)
+
+	return append(out, b[i:]...)
+}
+
+// bufferWriter holds an HTML response so it can be changed before
+// sending, writing any other response through as it comes.
+type bufferWriter struct {
+	http.ResponseWriter
+	status int
+	html   bool
+	body   bytes.Buffer
+}
+
+func (b *bufferWriter) WriteHeader(status int) {
+	if b.status != 0 {
+		return
+	}
+
+	b.status = status
+	b.html = strings.HasPrefix(b.Header().Get("Content-Type"), "text/html") &&
+		status != http.StatusNoContent && status != http.StatusNotModified
+
+	if !b.html {
+		b.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (b *bufferWriter) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+
+	if b.html {
+		return b.body.Write(p)
+	}
+
+	return b.ResponseWriter.Write(p)
+}
+
+// Flush sends what was written so far, unless it's held to inject.
+func (b *bufferWriter) Flush() {
+	if f, ok := b.ResponseWriter.(http.Flusher); ok && !b.html {
+		f.Flush()
+	}
+}
+
+func (b *bufferWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := b.ResponseWriter.(http.Hijacker)
+
+	if !ok {
+		return nil, nil, fmt.Errorf("hijacking unsupported")
+	}
+
+	return h.Hijack()
+}
@@ -0,0 +1,190 @@
+// Command abc serves, develops, builds and checks sites without a Go main.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sats-group/abc/pkg/web"
+)
+
+const usage = `usage: abc <command> [flags]
+
+commands:
+  new     create a starter site
+  serve   serve the site
+  dev     serve the site, reloading browsers on changes
+  build   export the site as static files with bundled assets
+  assets  prebuild asset bundles for -asset-cache
+  check   validate config, templates, assets and backends
+`
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cmd, args := os.Args[1], os.Args[2:]
+
+	switch cmd {
+	case "new":
+		scaffold(args)
+	case "serve":
+		serve(args)
+	case "dev":
+		dev(args)
+	case "build":
+		build(args)
+	case "assets":
+		assets(args)
+	case "check":
+		check(args)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func scaffold(args []string) {
+	fs := flag.NewFlagSet("abc new", flag.ExitOnError)
+	parse(fs, args)
+
+	dir := fs.Arg(0)
+
+	if dir == "" {
+		dir = "."
+	}
+
+	if err := web.Scaffold(dir); err != nil {
+		log.Fatalln(err)
+	}
+
+	log.Printf("created %s, run: abc dev -dir %s -layout layout.html -json %s\n", dir, dir, filepath.Join(dir, "site.json"))
+}
+
+func serve(args []string) {
+	fs, c := configFlags("serve")
+	parse(fs, args)
+	logErrors(c)
+
+	log.Fatalln(web.New(c).Serve())
+}
+
+func build(args []string) {
+	fs, c := configFlags("build")
+	out := fs.String("out", "dist", "output directory")
+	parse(fs, args)
+
+	c.Prod = true
+
+	if err := web.New(c).Export(*out); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func assets(args []string) {
+	fs, c := configFlags("assets")
+	out := fs.String("out", ".abc-assets", "output directory")
+	parse(fs, args)
+
+	manifest, err := web.BuildAssets(c.Dir, *out, c)
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	log.Printf("built %d bundles in %s\n", len(manifest), *out)
+}
+
+func check(args []string) {
+	fs, c := configFlags("check")
+	parse(fs, args)
+
+	if err := web.Validate(c); err != nil {
+		log.Fatalln(err)
+	}
+
+	for _, risk := range web.New(c).SecurityAudit() {
+		log.Println("security:", risk)
+	}
+
+	log.Println("ok")
+}
+
+// configFlags maps command line flags to a Config.
+func configFlags(name string) (*flag.FlagSet, *web.Config) {
+	c := &web.Config{}
+	fs := flag.NewFlagSet("abc "+name, flag.ExitOnError)
+
+	fs.StringVar(&c.Dir, "dir", ".", "site directory")
+	fs.StringVar(&c.Frontend, "frontend", "", "frontend URL (default http://localhost:8000/)")
+	fs.StringVar(&c.Backend, "backend", "", "backend URL")
+	fs.StringVar(&c.Candidate, "candidate", "", "candidate template directory")
+	fs.IntVar(&c.CandidatePercent, "candidate-percent", 0, "percentage of clients rendered with candidate templates")
+	fs.StringVar(&c.DevServer, "devserver", "", "frontend dev server URL proxied for assets in dev")
+	fs.StringVar(&c.Layout, "layout", "", "default layout template")
+	fs.StringVar(&c.NotFound, "notfound", "", "not found template")
+	fs.StringVar(&c.AssetVersion, "asset-version", "", "asset URL version (default hash of the site)")
+	fs.StringVar(&c.AssetCache, "asset-cache", "", "directory persisting built bundles in production")
+	fs.BoolVar(&c.AssetStore, "asset-store", false, "share built bundles through the cache store")
+	fs.StringVar(&c.PurgePath, "purge", "", "path of the cache purge endpoint")
+	fs.StringVar(&c.PurgeToken, "purge-token", "", "bearer token authorizing cache purges")
+	fs.StringVar(&c.Secret, "secret", "", "cookie signing secret")
+	fs.BoolVar(&c.Proxy, "proxy", false, "proxy unmatched requests to the backend")
+	fs.BoolVar(&c.Prod, "prod", false, "production mode")
+	fs.BoolVar(&c.Staging, "staging", false, "staging mode")
+	fs.BoolVar(&c.Stream, "stream", false, "stream rendered pages")
+	fs.BoolVar(&c.Audit, "audit", false, "report templates and settings weakening security")
+	fs.BoolVar(&c.AccessLog, "access-log", false, "log requests")
+	fs.StringVar(&c.AccessLogFile, "access-log-file", "", "access log file (default the error log)")
+	fs.StringVar(&c.ErrorLogFile, "error-log-file", "", "error log file (default stderr)")
+	fs.Int64Var(&c.LogRotation.MaxSize, "log-max-size", 0, "rotate log files larger than this many bytes")
+	fs.DurationVar(&c.LogRotation.MaxAge, "log-max-age", 0, "rotate log files written to for this long")
+	fs.IntVar(&c.LogRotation.Backups, "log-backups", 0, "rotated log files kept (default all)")
+	fs.Func("templates", "comma separated shared template directories", list(&c.TemplateDirs))
+	fs.Func("json", "comma separated JSON data files", list(&c.JSON))
+	fs.Func("auth", "comma separated user:pass@path patterns", list(&c.Auth))
+
+	return fs, c
+}
+
+// logErrors sends everything logged to the error log file, if given.
+func logErrors(c *web.Config) {
+	if c.ErrorLogFile == "" {
+		return
+	}
+
+	f, err := web.NewRotatingFile(c.ErrorLogFile, c.LogRotation)
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	c.ErrorLogWriter = f
+	log.SetOutput(f)
+}
+
+func parse(fs *flag.FlagSet, args []string) {
+	if err := fs.Parse(args); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func list(target *[]string) func(string) error {
+	return func(val string) error {
+		for _, item := range strings.Split(val, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*target = append(*target, item)
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,94 @@
+package files
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// DefaultPerm is the mode given to written files unless overridden.
+const DefaultPerm os.FileMode = 0644
+
+// DirPerm is the mode given to directories created for written files.
+const DirPerm os.FileMode = 0755
+
+// WriteOptions configures how files are written.
+type WriteOptions struct {
+	Perm os.FileMode
+	Sync bool
+}
+
+// Mode returns the mode files are written with.
+func (o WriteOptions) Mode() os.FileMode {
+	if o.Perm == 0 {
+		return DefaultPerm
+	}
+
+	return o.Perm
+}
+
+// WriteAtomic drains a source reader into a temporary file next to the
+// target and renames it into place, so readers never see a torn file.
+// With Sync set, the file and its directory are flushed to disk.
+func WriteAtomic(target string, source io.Reader, opts WriteOptions) error {
+	dir := filepath.Dir(target)
+	file, err := ioutil.TempFile(dir, "."+filepath.Base(target)+".tmp")
+
+	if err != nil {
+		return err
+	}
+
+	tmp := file.Name()
+
+	fail := func(err error) error {
+		_ = file.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if _, err := io.Copy(file, source); err != nil {
+		return fail(err)
+	}
+
+	if err := file.Chmod(opts.Mode()); err != nil {
+		return fail(err)
+	}
+
+	if opts.Sync {
+		if err := file.Sync(); err != nil {
+			return fail(err)
+		}
+	}
+
+	if err := file.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, target); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if opts.Sync {
+		return syncDir(dir)
+	}
+
+	return nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+
+	if err != nil {
+		return err
+	}
+
+	if err := d.Sync(); err != nil {
+		_ = d.Close()
+		return err
+	}
+
+	return d.Close()
+}
@@ -2,8 +2,10 @@
 package files
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"path"
@@ -20,7 +22,8 @@ var patterns = []*regexp.Regexp{
 	regexp.MustCompile(`/\.[^.]`),
 }
 
-// Ignore checks if a path includes leading dots or underscores.
+// Ignore checks if a path includes leading dots or underscores, or
+// matches the project's ignore patterns.
 func Ignore(path string) bool {
 	for _, pattern := range patterns {
 		if pattern.MatchString(path) {
@@ -28,7 +31,7 @@ func Ignore(path string) bool {
 		}
 	}
 
-	return false
+	return Excluded(path)
 }
 
 // Name will extract the filename, without the extension, from a path.
@@ -38,24 +41,51 @@ func Name(source string) string {
 
 // List will list all paths to files in the given source directory.
 func List(source string) []string {
+	files, err := ListE(source)
+
+	if err != nil {
+		return []string{}
+	}
+
+	return files
+}
+
+// ListE lists all paths to files in the given source directory, failing
+// when the source does not exist or cannot be read. Sources may be glob
+// patterns, listed in pattern order.
+func ListE(source string) ([]string, error) {
+	if IsGlob(source) && !Ignore(source) {
+		return glob(nil, source, PatternOrder)
+	}
+
+	return list(nil, source)
+}
+
+func list(fsys fs.FS, source string) ([]string, error) {
 	files := []string{}
 
 	if Ignore(source) {
-		return files
+		return files, nil
 	}
 
-	if HasFile(source) {
-		return []string{source}
+	stat, err := statIn(fsys, source)
+
+	if err != nil {
+		return nil, err
 	}
 
-	if !HasDir(source) {
-		return files
+	if !stat.IsDir() {
+		return []string{source}, nil
 	}
 
-	infos, err := ioutil.ReadDir(source)
+	infos, err := readDirIn(fsys, source)
 
 	if err != nil {
-		return files
+		return nil, err
+	}
+
+	if infos, err = ordered(fsys, source, infos); err != nil {
+		return nil, err
 	}
 
 	for _, info := range infos {
@@ -66,28 +96,51 @@ func List(source string) []string {
 		}
 
 		if info.IsDir() {
-			files = append(files, List(rel)...)
+			nested, err := list(fsys, rel)
+
+			if err != nil {
+				return nil, err
+			}
+
+			files = append(files, nested...)
 			continue
 		}
 
 		files = append(files, rel)
 	}
 
-	return files
+	return files, nil
 }
 
 // ListType will list all paths to files with the given extension.
 func ListType(source, ext string) []string {
+	files, err := ListTypeE(source, ext)
+
+	if err != nil {
+		return []string{}
+	}
+
+	return files
+}
+
+// ListTypeE lists all paths to files with the given extension, failing
+// when the source does not exist or cannot be read.
+func ListTypeE(source, ext string) ([]string, error) {
 	dext := "." + strings.TrimPrefix(ext, ".")
 	matches := []string{}
+	files, err := ListE(source)
+
+	if err != nil {
+		return nil, err
+	}
 
-	for _, file := range List(source) {
+	for _, file := range files {
 		if path.Ext(file) == dext {
 			matches = append(matches, file)
 		}
 	}
 
-	return matches
+	return matches, nil
 }
 
 // Copy will copy a file from source to target.
@@ -129,6 +182,12 @@ func Copy(source, target string) error {
 
 // Drain will empty a source reader and save it to the target path.
 func Drain(target string, source io.Reader) error {
+	return DrainOptions(target, source, WriteOptions{})
+}
+
+// DrainOptions empties a source reader and atomically saves it to the
+// target path with the given options.
+func DrainOptions(target string, source io.Reader, opts WriteOptions) error {
 	if Ignore(target) {
 		return nil
 	}
@@ -137,26 +196,12 @@ func Drain(target string, source io.Reader) error {
 		return err
 	}
 
-	file, err := os.Create(target)
-
-	if err != nil {
-		return err
-	}
-
-	if _, err := io.Copy(file, source); err != nil {
-		return err
-	}
-
-	if err := file.Close(); err != nil {
-		return err
-	}
-
-	return nil
+	return WriteAtomic(target, source, opts)
 }
 
 // Read will read the contents of a file.
 func Read(source string) []byte {
-	bytes, err := ioutil.ReadFile(source)
+	bytes, err := ReadE(source)
 
 	if err != nil {
 		return []byte{}
@@ -165,9 +210,20 @@ func Read(source string) []byte {
 	return bytes
 }
 
-// Write will write data to a target file.
+// ReadE reads the contents of a file, failing if it cannot be read.
+func ReadE(source string) ([]byte, error) {
+	return ioutil.ReadFile(source)
+}
+
+// Write will atomically write data to a target file.
 func Write(target string, data []byte) error {
-	return ioutil.WriteFile(target, data, os.ModePerm)
+	return WriteWith(target, data, WriteOptions{})
+}
+
+// WriteWith atomically writes data to a target file with the given
+// options.
+func WriteWith(target string, data []byte, opts WriteOptions) error {
+	return WriteAtomic(target, bytes.NewReader(data), opts)
 }
 
 // HasDir checks if a dir exists at the given path.
@@ -195,7 +251,7 @@ func HasFile(source string) bool {
 // MkdirAll will create a directory tree if it does not exist.
 func MkdirAll(target string) error {
 	if !HasDir(target) {
-		if err := os.MkdirAll(target, os.ModePerm); err != nil {
+		if err := os.MkdirAll(target, DirPerm); err != nil {
 			return err
 		}
 	}
@@ -253,37 +309,58 @@ func Walk(source string, fn func(string) error) error {
 
 // TempDir creates a temporary directory for a callback.
 func TempDir(name string, fn func(string)) {
+	_ = TempDirE(name, func(dir string) error {
+		fn(dir)
+		return nil
+	})
+}
+
+// TempDirE creates a temporary directory for a callback, returning any
+// error from creating, using or removing it.
+func TempDirE(name string, fn func(string) error) error {
 	dir, err := ioutil.TempDir(os.TempDir(), "temp-dir")
 
 	if err != nil {
-		return
+		return err
 	}
 
-	fn(dir)
-
-	if os.RemoveAll(dir) != nil {
-		return
+	if err := fn(dir); err != nil {
+		_ = os.RemoveAll(dir)
+		return err
 	}
+
+	return os.RemoveAll(dir)
 }
 
 // TempFile creates a temporary file for a callback.
 func TempFile(name string, data string, fn func(string)) {
+	_ = TempFileE(name, data, func(dir string) error {
+		fn(dir)
+		return nil
+	})
+}
+
+// TempFileE creates a temporary file for a callback, returning any
+// error from creating, using or removing it.
+func TempFileE(name string, data string, fn func(string) error) error {
 	dir, err := ioutil.TempDir(os.TempDir(), "temp-file")
 
 	if err != nil {
-		return
+		return err
 	}
 
 	abs := path.Join(dir, name)
 	bit := []byte(data)
 
 	if err := ioutil.WriteFile(abs, bit, os.ModePerm); err != nil {
-		return
+		_ = os.RemoveAll(dir)
+		return err
 	}
 
-	fn(dir)
-
-	if err := os.RemoveAll(dir); err != nil {
-		return
+	if err := fn(dir); err != nil {
+		_ = os.RemoveAll(dir)
+		return err
 	}
+
+	return os.RemoveAll(dir)
 }
@@ -0,0 +1,137 @@
+package files
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ListFS lists all paths to files in the given source directory of a
+// filesystem, like ListE.
+func ListFS(fsys fs.FS, source string) ([]string, error) {
+	if IsGlob(source) && !Ignore(source) {
+		return glob(fsys, source, PatternOrder)
+	}
+
+	return list(fsys, source)
+}
+
+// ListTypeFS lists all paths to files with the given extension in a
+// filesystem, like ListTypeE.
+func ListTypeFS(fsys fs.FS, source, ext string) ([]string, error) {
+	dext := "." + strings.TrimPrefix(ext, ".")
+	matches := []string{}
+	files, err := ListFS(fsys, source)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		if path.Ext(file) == dext {
+			matches = append(matches, file)
+		}
+	}
+
+	return matches, nil
+}
+
+// GlobFS lists files in a filesystem matching a glob pattern, like GlobE.
+func GlobFS(fsys fs.FS, pattern string, order int) ([]string, error) {
+	return glob(fsys, pattern, order)
+}
+
+// ReadFS reads the contents of a file in a filesystem.
+func ReadFS(fsys fs.FS, source string) ([]byte, error) {
+	return fs.ReadFile(fsys, source)
+}
+
+// WalkFS traverses files in a filesystem like Walk, passing paths
+// relative to the source directory.
+func WalkFS(fsys fs.FS, source string, fn func(string) error) error {
+	return fs.WalkDir(fsys, source,
+		func(name string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if entry.IsDir() {
+				return nil
+			}
+
+			if source == "." {
+				return fn(name)
+			}
+
+			return fn(strings.TrimPrefix(name, source+"/"))
+		})
+}
+
+// HasDirFS checks if a dir exists at the given path in a filesystem.
+func HasDirFS(fsys fs.FS, source string) bool {
+	stat, err := fs.Stat(fsys, source)
+
+	return err == nil && stat.IsDir()
+}
+
+// HasFileFS checks if a file exists at the given path in a filesystem.
+func HasFileFS(fsys fs.FS, source string) bool {
+	stat, err := fs.Stat(fsys, source)
+
+	return err == nil && !stat.IsDir()
+}
+
+// The helpers below use the OS filesystem when fsys is nil.
+
+func statIn(fsys fs.FS, name string) (os.FileInfo, error) {
+	if fsys == nil {
+		return os.Stat(name)
+	}
+
+	return fs.Stat(fsys, name)
+}
+
+func readDirIn(fsys fs.FS, name string) ([]os.FileInfo, error) {
+	if fsys == nil {
+		return ioutil.ReadDir(name)
+	}
+
+	entries, err := fs.ReadDir(fsys, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]os.FileInfo, 0, len(entries))
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+
+		if err != nil {
+			return nil, err
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+func openIn(fsys fs.FS, name string) (fs.File, error) {
+	if fsys == nil {
+		return os.Open(name)
+	}
+
+	return fsys.Open(name)
+}
+
+func globIn(fsys fs.FS, pattern string) ([]string, error) {
+	if fsys == nil {
+		return filepath.Glob(pattern)
+	}
+
+	return fs.Glob(fsys, pattern)
+}
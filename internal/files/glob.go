@@ -0,0 +1,123 @@
+package files
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Orders for files matched by a glob pattern.
+const (
+	// PatternOrder keeps brace alternatives in the order they are written,
+	// with matches of each alternative sorted alphabetically.
+	PatternOrder = iota
+
+	// Alphabetical sorts all matches alphabetically.
+	Alphabetical
+)
+
+// IsGlob checks if a path contains glob or brace-expansion patterns.
+func IsGlob(source string) bool {
+	return strings.ContainsAny(source, "*?[{")
+}
+
+// Expand performs brace expansion, so {a,b}/c.css becomes a/c.css and
+// b/c.css.
+func Expand(pattern string) []string {
+	open := strings.Index(pattern, "{")
+
+	if open == -1 {
+		return []string{pattern}
+	}
+
+	depth, start := 0, open+1
+	parts := []string{}
+
+	for i := open; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			depth++
+		case ',':
+			if depth == 1 {
+				parts = append(parts, pattern[start:i])
+				start = i + 1
+			}
+		case '}':
+			if depth--; depth == 0 {
+				parts = append(parts, pattern[start:i])
+				return expandParts(pattern[:open], parts, pattern[i+1:])
+			}
+		}
+	}
+
+	return []string{pattern}
+}
+
+func expandParts(prefix string, parts []string, suffix string) []string {
+	out := []string{}
+
+	for _, part := range parts {
+		out = append(out, Expand(prefix+part+suffix)...)
+	}
+
+	return out
+}
+
+// Glob lists files matching a glob pattern with brace expansion.
+func Glob(pattern string, order int) []string {
+	files, err := GlobE(pattern, order)
+
+	if err != nil {
+		return []string{}
+	}
+
+	return files
+}
+
+// GlobE lists files matching a glob pattern with brace expansion, where
+// matching directories contribute all files within them. Ignored paths
+// are skipped and every file is listed once.
+func GlobE(pattern string, order int) ([]string, error) {
+	return glob(nil, pattern, order)
+}
+
+func glob(fsys fs.FS, pattern string, order int) ([]string, error) {
+	seen := map[string]bool{}
+	files := []string{}
+
+	for _, alt := range Expand(pattern) {
+		matches, err := globIn(fsys, alt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		sort.Strings(matches)
+
+		for _, match := range matches {
+			if Ignore(match) {
+				continue
+			}
+
+			listed, err := list(fsys, filepath.ToSlash(match))
+
+			if err != nil {
+				return nil, err
+			}
+
+			for _, file := range listed {
+				if !seen[file] {
+					seen[file] = true
+					files = append(files, file)
+				}
+			}
+		}
+	}
+
+	if order == Alphabetical {
+		sort.Strings(files)
+	}
+
+	return files, nil
+}
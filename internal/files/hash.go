@@ -0,0 +1,118 @@
+package files
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+type hashEntry struct {
+	size int64
+	time time.Time
+	sum  string
+}
+
+var hashes = struct {
+	sync.Mutex
+	entries map[string]hashEntry
+}{entries: map[string]hashEntry{}}
+
+// Hash returns the hex-encoded SHA-256 of a file's contents, reusing the
+// previous result while its size and modification time are unchanged.
+func Hash(source string) (string, error) {
+	info, err := Source(source)
+
+	if err != nil {
+		return "", err
+	}
+
+	hashes.Lock()
+	entry, ok := hashes.entries[source]
+	hashes.Unlock()
+
+	if ok && entry.size == info.Size() && entry.time.Equal(info.ModTime()) {
+		return entry.sum, nil
+	}
+
+	file, err := os.Open(source)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	h := sha256.New()
+
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	hashes.Lock()
+	hashes.entries[source] = hashEntry{size: info.Size(), time: info.ModTime(), sum: sum}
+	hashes.Unlock()
+
+	return sum, nil
+}
+
+// HashDir returns a Merkle-style hash of a directory, combining the
+// relative path and content hash of every file that is not ignored, so
+// any added, removed, renamed or changed file alters the result.
+func HashDir(source string) (string, error) {
+	rels := []string{}
+
+	err := Walk(source, func(rel string) error {
+		if !Ignore(filepath.ToSlash(rel)) {
+			rels = append(rels, rel)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	sort.Strings(rels)
+	h := sha256.New()
+
+	for _, rel := range rels {
+		sum, err := Hash(filepath.Join(source, rel))
+
+		if err != nil {
+			return "", err
+		}
+
+		_, _ = io.WriteString(h, filepath.ToSlash(rel)+"\x00"+sum+"\n")
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Changed reports whether a file or directory hash differs from a
+// previously recorded one.
+func Changed(source string, previous string) (bool, error) {
+	sum, err := HashPath(source)
+
+	if err != nil {
+		return false, err
+	}
+
+	return sum != previous, nil
+}
+
+// HashPath hashes a file or a directory, whichever is at the path.
+func HashPath(source string) (string, error) {
+	if HasDir(source) {
+		return HashDir(source)
+	}
+
+	return Hash(source)
+}
@@ -0,0 +1,157 @@
+package files
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// IgnoreFile names a project-level file of gitignore-style patterns.
+const IgnoreFile = ".abcignore"
+
+type rule struct {
+	pattern *regexp.Regexp
+	negate  bool
+}
+
+var excludes = struct {
+	sync.RWMutex
+	root   string
+	loaded []rule
+	added  []rule
+}{}
+
+// LoadIgnore reads the ignore file in a project root, if there is one,
+// replacing the patterns loaded before with its own relative to that
+// root. Patterns added with AddIgnore are kept.
+func LoadIgnore(root string) error {
+	file, err := os.Open(filepath.Join(root, IgnoreFile))
+
+	if os.IsNotExist(err) {
+		excludes.Lock()
+		excludes.root, excludes.loaded = "", nil
+		excludes.Unlock()
+
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	patterns := []string{}
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		patterns = append(patterns, scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	excludes.Lock()
+	excludes.root = path.Clean(filepath.ToSlash(root))
+	excludes.loaded = parseIgnore(patterns)
+	excludes.Unlock()
+
+	return nil
+}
+
+// AddIgnore adds gitignore-style patterns for paths to ignore.
+func AddIgnore(patterns ...string) {
+	rules := parseIgnore(patterns)
+
+	excludes.Lock()
+	excludes.added = append(excludes.added, rules...)
+	excludes.Unlock()
+}
+
+func parseIgnore(patterns []string) []rule {
+	rules := []rule{}
+
+	for _, p := range patterns {
+		p = strings.TrimRight(p, " \t")
+
+		if p == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+
+		r := rule{}
+
+		if strings.HasPrefix(p, "!") {
+			r.negate, p = true, p[1:]
+		}
+
+		r.pattern = compileIgnore(p)
+		rules = append(rules, r)
+	}
+
+	return rules
+}
+
+// Excluded checks if a path matches the project's ignore patterns.
+func Excluded(source string) bool {
+	excludes.RLock()
+	defer excludes.RUnlock()
+
+	if len(excludes.loaded) == 0 && len(excludes.added) == 0 {
+		return false
+	}
+
+	rel := filepath.ToSlash(source)
+
+	if excludes.root != "" && excludes.root != "." && strings.HasPrefix(rel, excludes.root+"/") {
+		rel = rel[len(excludes.root)+1:]
+	}
+
+	rel = strings.TrimPrefix(strings.TrimPrefix(rel, "./"), "/")
+	excluded := false
+
+	for _, rules := range [][]rule{excludes.loaded, excludes.added} {
+		for _, r := range rules {
+			if r.pattern.MatchString(rel) {
+				excluded = !r.negate
+			}
+		}
+	}
+
+	return excluded
+}
+
+// compileIgnore turns a gitignore pattern into a regexp matching a path
+// or anything below it.
+func compileIgnore(p string) *regexp.Regexp {
+	anchored := strings.Contains(strings.TrimSuffix(p, "/"), "/")
+	p = strings.TrimSuffix(strings.TrimPrefix(p, "/"), "/")
+	out := ""
+
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; {
+		case strings.HasPrefix(p[i:], "**/"):
+			out += "(.*/)?"
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			out += ".*"
+			i++
+		case c == '*':
+			out += "[^/]*"
+		case c == '?':
+			out += "[^/]"
+		default:
+			out += regexp.QuoteMeta(string(c))
+		}
+	}
+
+	if !anchored {
+		out = "(.*/)?" + out
+	}
+
+	return regexp.MustCompile("^" + out + "(/.*)?$")
+}
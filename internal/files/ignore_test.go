@@ -0,0 +1,80 @@
+package files
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnore(t *testing.T) {
+	root, err := ioutil.TempDir("", "ignore")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(root)
+	defer resetIgnore()
+
+	ignore := "# drafts\n\n*.draft.html\n/build\nprivate/\n!private/public.html\ndocs/**/*.md\n"
+
+	if err := ioutil.WriteFile(filepath.Join(root, IgnoreFile), []byte(ignore), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadIgnore(root); err != nil {
+		t.Fatal(err)
+	}
+
+	AddIgnore("scratch/")
+
+	excluded := []string{
+		"post.draft.html",
+		"blog/post.draft.html",
+		"build/app.js",
+		"private/notes.html",
+		"docs/api/v1/readme.md",
+		"scratch/test.html",
+	}
+
+	included := []string{
+		"index.html",
+		"draft.html",
+		"src/build/app.js",
+		"private/public.html",
+		"docs/readme.txt",
+	}
+
+	for _, p := range excluded {
+		if !Excluded(filepath.Join(root, p)) {
+			t.Errorf("%s not excluded", p)
+		}
+	}
+
+	for _, p := range included {
+		if Excluded(filepath.Join(root, p)) {
+			t.Errorf("%s excluded", p)
+		}
+	}
+
+	// Reloading a root without an ignore file drops its patterns, but
+	// not the added ones.
+	if err := LoadIgnore(filepath.Join(root, "missing")); err != nil {
+		t.Fatal(err)
+	}
+
+	if Excluded("private/notes.html") {
+		t.Error("loaded patterns kept after reloading")
+	}
+
+	if !Excluded("scratch/test.html") {
+		t.Error("added patterns dropped after reloading")
+	}
+}
+
+func resetIgnore() {
+	excludes.Lock()
+	excludes.root, excludes.loaded, excludes.added = "", nil, nil
+	excludes.Unlock()
+}
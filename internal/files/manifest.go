@@ -0,0 +1,126 @@
+package files
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ManifestFile names an optional file ordering a directory's listing.
+// Each line names an entry (or a glob of entries) to list in that
+// order, lines starting with "!" exclude entries and "#" starts a
+// comment. Unnamed entries follow in numbered-prefix order.
+const ManifestFile = "_manifest"
+
+func ordered(fsys fs.FS, dir string, infos []os.FileInfo) ([]os.FileInfo, error) {
+	sort.SliceStable(infos, func(i, j int) bool {
+		return naturalLess(infos[i].Name(), infos[j].Name())
+	})
+
+	file, err := openIn(fsys, path.Join(dir, ManifestFile))
+
+	if os.IsNotExist(err) {
+		return infos, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	includes, excludes := []string{}, []string{}
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "!"):
+			excludes = append(excludes, strings.TrimSpace(line[1:]))
+		default:
+			includes = append(includes, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	out := []os.FileInfo{}
+	used := map[string]bool{}
+
+	for _, pattern := range includes {
+		for _, info := range infos {
+			if !used[info.Name()] && matchName(pattern, info.Name()) {
+				used[info.Name()] = true
+				out = append(out, info)
+			}
+		}
+	}
+
+	for _, info := range infos {
+		if !used[info.Name()] {
+			out = append(out, info)
+		}
+	}
+
+	kept := out[:0]
+
+	for _, info := range out {
+		excluded := false
+
+		for _, pattern := range excludes {
+			excluded = excluded || matchName(pattern, info.Name())
+		}
+
+		if !excluded {
+			kept = append(kept, info)
+		}
+	}
+
+	return kept, nil
+}
+
+func matchName(pattern, name string) bool {
+	ok, err := path.Match(strings.TrimSuffix(pattern, "/"), name)
+	return err == nil && ok
+}
+
+// naturalLess orders names by any leading number first, so 2-a.js comes
+// before 10-b.js, then by name.
+func naturalLess(a, b string) bool {
+	na, ra := leadingNumber(a)
+	nb, rb := leadingNumber(b)
+
+	if ra && rb && na != nb {
+		return na < nb
+	}
+
+	if ra != rb {
+		return ra
+	}
+
+	return a < b
+}
+
+func leadingNumber(name string) (int, bool) {
+	end := 0
+
+	for end < len(name) && name[end] >= '0' && name[end] <= '9' {
+		end++
+	}
+
+	if end == 0 {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(name[:end])
+
+	return n, err == nil
+}
@@ -0,0 +1,82 @@
+package files
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+)
+
+// BytesProgress reports bytes copied so far and the total, which is -1
+// when unknown.
+type BytesProgress func(copied int64, total int64)
+
+type progressReader struct {
+	ctx      context.Context
+	r        io.Reader
+	copied   int64
+	total    int64
+	progress BytesProgress
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	if err := p.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := p.r.Read(b)
+	p.copied += int64(n)
+
+	if n > 0 && p.progress != nil {
+		p.progress(p.copied, p.total)
+	}
+
+	return n, err
+}
+
+// CopyWithProgress copies a file from source to target like Copy,
+// reporting progress and stopping when the context is cancelled. The
+// target is written atomically, so a cancelled copy leaves no partial
+// file behind.
+func CopyWithProgress(ctx context.Context, source, target string, progress BytesProgress) error {
+	if Ignore(source) || Ignore(target) {
+		return nil
+	}
+
+	info, err := Source(source)
+
+	if err != nil {
+		return err
+	}
+
+	input, err := os.Open(source)
+
+	if err != nil {
+		return err
+	}
+
+	defer input.Close()
+
+	return drain(ctx, target, input, info.Size(), WriteOptions{}, progress)
+}
+
+// DrainContext empties a source reader into the target path like
+// DrainOptions, reporting progress and stopping when the context is
+// cancelled.
+func DrainContext(ctx context.Context, target string, source io.Reader, opts WriteOptions, progress BytesProgress) error {
+	if Ignore(target) {
+		return nil
+	}
+
+	return drain(ctx, target, source, -1, opts, progress)
+}
+
+func drain(ctx context.Context, target string, source io.Reader, total int64, opts WriteOptions, progress BytesProgress) error {
+	if err := MkdirAll(path.Dir(target)); err != nil {
+		return err
+	}
+
+	r := &progressReader{ctx: ctx, r: source, total: total, progress: progress}
+
+	return WriteAtomic(target, r, opts)
+}
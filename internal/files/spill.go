@@ -0,0 +1,122 @@
+package files
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// A Buffer keeps written data in memory up to a limit, then spills it
+// all to a temporary file. Close removes the file.
+type Buffer struct {
+	limit int64
+	size  int64
+	mem   bytes.Buffer
+	file  *os.File
+}
+
+// NewBuffer creates a buffer that holds up to limit bytes in memory.
+func NewBuffer(limit int64) *Buffer {
+	return &Buffer{limit: limit}
+}
+
+// Spool copies a reader into a new buffer.
+func Spool(source io.Reader, limit int64) (*Buffer, error) {
+	b := NewBuffer(limit)
+
+	if _, err := io.Copy(b, source); err != nil {
+		_ = b.Close()
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Write appends to the buffer, spilling to disk past the limit.
+func (b *Buffer) Write(p []byte) (int, error) {
+	if b.file == nil && b.size+int64(len(p)) > b.limit {
+		if err := b.spill(); err != nil {
+			return 0, err
+		}
+	}
+
+	var n int
+	var err error
+
+	if b.file != nil {
+		n, err = b.file.Write(p)
+	} else {
+		n, err = b.mem.Write(p)
+	}
+
+	b.size += int64(n)
+
+	return n, err
+}
+
+func (b *Buffer) spill() error {
+	file, err := ioutil.TempFile("", "abc-buffer-")
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := b.mem.WriteTo(file); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return err
+	}
+
+	b.file = file
+
+	return nil
+}
+
+// Reader reads the buffered data from the start. Each call returns a new
+// reader.
+func (b *Buffer) Reader() io.Reader {
+	if b.file != nil {
+		return io.NewSectionReader(b.file, 0, b.size)
+	}
+
+	return bytes.NewReader(b.mem.Bytes())
+}
+
+// Bytes returns the buffered data, reading it back from disk if spilled.
+func (b *Buffer) Bytes() ([]byte, error) {
+	if b.file == nil {
+		return b.mem.Bytes(), nil
+	}
+
+	return ioutil.ReadAll(b.Reader())
+}
+
+// Len is the number of bytes buffered.
+func (b *Buffer) Len() int64 {
+	return b.size
+}
+
+// Spilled reports whether the data went to disk.
+func (b *Buffer) Spilled() bool {
+	return b.file != nil
+}
+
+// Close releases the buffer, removing any temporary file.
+func (b *Buffer) Close() error {
+	b.mem.Reset()
+
+	if b.file == nil {
+		return nil
+	}
+
+	name := b.file.Name()
+	err := b.file.Close()
+	b.file = nil
+
+	if rmErr := os.Remove(name); err == nil {
+		err = rmErr
+	}
+
+	return err
+}
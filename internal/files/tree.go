@@ -0,0 +1,65 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// CopyProgress reports each copied file with counts of files done and in
+// total.
+type CopyProgress func(rel string, done int, total int)
+
+// CopyTree copies every file below source to the same relative path
+// below target, skipping ignored paths and files the filter rejects.
+// A nil filter copies everything.
+func CopyTree(source, target string, filter func(rel string) bool) error {
+	return CopyTreeProgress(source, target, filter, nil)
+}
+
+// CopyTreeProgress copies a directory tree like CopyTree, preserving
+// modification times and reporting progress after each file.
+func CopyTreeProgress(source, target string, filter func(rel string) bool, progress CopyProgress) error {
+	rels := []string{}
+
+	err := Walk(source, func(rel string) error {
+		if Ignore(filepath.ToSlash(rel)) {
+			return nil
+		}
+
+		if filter != nil && !filter(rel) {
+			return nil
+		}
+
+		rels = append(rels, rel)
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for i, rel := range rels {
+		src := filepath.Join(source, rel)
+		dst := filepath.Join(target, rel)
+		info, err := Source(src)
+
+		if err != nil {
+			return err
+		}
+
+		if err := Copy(src, dst); err != nil {
+			return err
+		}
+
+		if err := os.Chtimes(dst, info.ModTime(), info.ModTime()); err != nil {
+			return err
+		}
+
+		if progress != nil {
+			progress(rel, i+1, len(rels))
+		}
+	}
+
+	return nil
+}
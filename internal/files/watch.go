@@ -0,0 +1,181 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Operations reported by Watch.
+const (
+	Create = iota + 1
+	Modify
+	Delete
+)
+
+// Timings used by Watch to poll a tree and settle bursts of changes.
+var (
+	WatchInterval = 250 * time.Millisecond
+	WatchDebounce = 100 * time.Millisecond
+)
+
+// Event describes a change to a file below a watched root.
+type Event struct {
+	Path string
+	Op   int
+}
+
+// Watcher polls a directory tree for changes.
+type Watcher struct {
+	root  string
+	fn    func([]Event)
+	files map[string]os.FileInfo
+	done  chan struct{}
+	once  sync.Once
+}
+
+// Watch recursively watches a tree, skipping ignored paths, and calls fn
+// with each settled burst of changes. Paths are relative to the root.
+func Watch(root string, fn func([]Event)) (*Watcher, error) {
+	files, err := snapshot(root)
+
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		root:  root,
+		fn:    fn,
+		files: files,
+		done:  make(chan struct{}),
+	}
+
+	go w.loop()
+
+	return w, nil
+}
+
+// Close stops watching.
+func (w *Watcher) Close() {
+	w.once.Do(func() { close(w.done) })
+}
+
+func (w *Watcher) loop() {
+	ticker := time.NewTicker(WatchInterval)
+	defer ticker.Stop()
+
+	pending := map[string]int{}
+	changed := time.Time{}
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		files, err := snapshot(w.root)
+
+		if err != nil {
+			continue
+		}
+
+		for _, e := range diff(w.files, files) {
+			pending[e.Path] = merge(pending[e.Path], e.Op)
+			changed = time.Now()
+		}
+
+		w.files = files
+
+		if len(pending) == 0 || time.Since(changed) < WatchDebounce {
+			continue
+		}
+
+		events := []Event{}
+
+		for rel, op := range pending {
+			if op != 0 {
+				events = append(events, Event{Path: rel, Op: op})
+			}
+		}
+
+		pending = map[string]int{}
+
+		if len(events) > 0 {
+			w.fn(events)
+		}
+	}
+}
+
+func snapshot(root string) (map[string]os.FileInfo, error) {
+	files := map[string]os.FileInfo{}
+
+	err := filepath.Walk(root, func(abs string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, abs)
+
+		if err != nil || rel == "." {
+			return err
+		}
+
+		if Ignore(filepath.ToSlash(rel)) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !info.IsDir() {
+			files[filepath.ToSlash(rel)] = info
+		}
+
+		return nil
+	})
+
+	return files, err
+}
+
+func diff(before, after map[string]os.FileInfo) []Event {
+	events := []Event{}
+
+	for rel, info := range after {
+		old, ok := before[rel]
+
+		switch {
+		case !ok:
+			events = append(events, Event{Path: rel, Op: Create})
+		case !old.ModTime().Equal(info.ModTime()) || old.Size() != info.Size():
+			events = append(events, Event{Path: rel, Op: Modify})
+		}
+	}
+
+	for rel := range before {
+		if _, ok := after[rel]; !ok {
+			events = append(events, Event{Path: rel, Op: Delete})
+		}
+	}
+
+	return events
+}
+
+// merge folds a new operation into a pending one, so a file created and
+// then deleted within a burst is not reported at all.
+func merge(pending, op int) int {
+	switch {
+	case pending == 0:
+		return op
+	case pending == Create && op == Delete:
+		return 0
+	case pending == Create:
+		return Create
+	case pending == Delete && op == Create:
+		return Modify
+	default:
+		return op
+	}
+}
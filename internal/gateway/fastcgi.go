@@ -0,0 +1,175 @@
+package gateway
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+// FastCGI record types.
+const (
+	fcgiBeginRequest = 1
+	fcgiEndRequest   = 3
+	fcgiParams       = 4
+	fcgiStdin        = 5
+	fcgiStdout       = 6
+	fcgiStderr       = 7
+)
+
+const (
+	fcgiVersion   = 1
+	fcgiResponder = 1
+	fcgiRequestID = 1
+	fcgiMaxWrite  = 65535
+)
+
+func fastcgi(conn net.Conn, r *http.Request, env map[string]string, body io.Reader) (*http.Response, error) {
+	w := bufio.NewWriter(conn)
+	begin := []byte{0, fcgiResponder, 0, 0, 0, 0, 0, 0}
+
+	err := fcgiWrite(w, fcgiBeginRequest, begin)
+
+	if err == nil {
+		err = fcgiWriteStream(w, fcgiParams, fcgiPairs(env))
+	}
+
+	if err == nil {
+		err = fcgiCopy(w, body)
+	}
+
+	if err == nil {
+		err = w.Flush()
+	}
+
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+
+	go fcgiRead(conn, pw)
+
+	return readCGI(r, readCloser{pr, conn})
+}
+
+func fcgiRead(conn net.Conn, pw *io.PipeWriter) {
+	br := bufio.NewReader(conn)
+	head := make([]byte, 8)
+
+	for {
+		if _, err := io.ReadFull(br, head); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+
+		size := int(binary.BigEndian.Uint16(head[4:6])) + int(head[6])
+		content := make([]byte, size)
+
+		if _, err := io.ReadFull(br, content); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+
+		content = content[:binary.BigEndian.Uint16(head[4:6])]
+
+		switch head[1] {
+		case fcgiStdout:
+			if _, err := pw.Write(content); err != nil {
+				return
+			}
+		case fcgiEndRequest:
+			pw.Close()
+			return
+		case fcgiStderr:
+		default:
+			pw.CloseWithError(errors.New("fastcgi: unexpected record type"))
+			return
+		}
+	}
+}
+
+func fcgiCopy(w io.Writer, body io.Reader) error {
+	buf := make([]byte, fcgiMaxWrite)
+
+	for {
+		n, err := body.Read(buf)
+
+		if n > 0 {
+			if werr := fcgiWrite(w, fcgiStdin, buf[:n]); werr != nil {
+				return werr
+			}
+		}
+
+		if err == io.EOF {
+			return fcgiWrite(w, fcgiStdin, nil)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func fcgiWriteStream(w io.Writer, kind byte, data []byte) error {
+	for len(data) > 0 {
+		n := len(data)
+
+		if n > fcgiMaxWrite {
+			n = fcgiMaxWrite
+		}
+
+		if err := fcgiWrite(w, kind, data[:n]); err != nil {
+			return err
+		}
+
+		data = data[n:]
+	}
+
+	return fcgiWrite(w, kind, nil)
+}
+
+func fcgiWrite(w io.Writer, kind byte, content []byte) error {
+	pad := (8 - len(content)%8) % 8
+	head := []byte{fcgiVersion, kind, 0, fcgiRequestID, 0, 0, byte(pad), 0}
+	binary.BigEndian.PutUint16(head[4:6], uint16(len(content)))
+
+	if _, err := w.Write(head); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(content); err != nil {
+		return err
+	}
+
+	_, err := w.Write(make([]byte, pad))
+
+	return err
+}
+
+func fcgiPairs(env map[string]string) []byte {
+	out := []byte{}
+
+	for key, val := range env {
+		out = fcgiLength(out, len(key))
+		out = fcgiLength(out, len(val))
+		out = append(out, key...)
+		out = append(out, val...)
+	}
+
+	return out
+}
+
+func fcgiLength(out []byte, n int) []byte {
+	if n < 128 {
+		return append(out, byte(n))
+	}
+
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(n)|1<<31)
+
+	return append(out, b...)
+}
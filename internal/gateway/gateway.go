@@ -0,0 +1,191 @@
+// Package gateway proxies HTTP requests to FastCGI and uwsgi servers.
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/textproto"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Protocols supported by a Transport.
+const (
+	FastCGI = "fastcgi"
+	UWSGI   = "uwsgi"
+)
+
+// A Transport is an http.RoundTripper speaking FastCGI or uwsgi.
+type Transport struct {
+	Protocol string
+	Network  string
+	Address  string
+	Root     string
+	Index    string
+	Timeout  time.Duration
+}
+
+// RoundTrip sends a request to the gateway server and reads its response.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	body, size, err := readBody(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := net.Dialer{Timeout: t.Timeout}
+	conn, err := dialer.DialContext(r.Context(), t.network(), t.Address)
+
+	if err != nil {
+		return nil, err
+	}
+
+	env := t.env(r, size)
+
+	switch t.Protocol {
+	case FastCGI:
+		return fastcgi(conn, r, env, body)
+	case UWSGI:
+		return uwsgi(conn, r, env, body)
+	}
+
+	_ = conn.Close()
+
+	return nil, fmt.Errorf("unknown gateway protocol: %s", t.Protocol)
+}
+
+func (t *Transport) network() string {
+	if t.Network == "" {
+		return "tcp"
+	}
+
+	return t.Network
+}
+
+// env builds the CGI variables for a request.
+func (t *Transport) env(r *http.Request, size int64) map[string]string {
+	host, port, err := net.SplitHostPort(r.Host)
+
+	if err != nil {
+		host, port = r.Host, "80"
+	}
+
+	raddr, rport, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		raddr = r.RemoteAddr
+	}
+
+	script, info := r.URL.Path, ""
+
+	if t.Index != "" {
+		script, info = "/"+strings.TrimPrefix(t.Index, "/"), r.URL.Path
+	}
+
+	env := map[string]string{
+		"GATEWAY_INTERFACE": "CGI/1.1",
+		"SERVER_SOFTWARE":   "abc",
+		"SERVER_PROTOCOL":   r.Proto,
+		"SERVER_NAME":       host,
+		"SERVER_PORT":       port,
+		"REQUEST_METHOD":    r.Method,
+		"REQUEST_URI":       r.URL.RequestURI(),
+		"QUERY_STRING":      r.URL.RawQuery,
+		"DOCUMENT_ROOT":     t.Root,
+		"DOCUMENT_URI":      script,
+		"SCRIPT_NAME":       script,
+		"SCRIPT_FILENAME":   path.Join(t.Root, script),
+		"PATH_INFO":         info,
+		"REMOTE_ADDR":       raddr,
+		"REMOTE_PORT":       rport,
+		"CONTENT_TYPE":      r.Header.Get("Content-Type"),
+		"CONTENT_LENGTH":    strconv.FormatInt(size, 10),
+	}
+
+	if r.TLS != nil {
+		env["HTTPS"] = "on"
+	}
+
+	if r.Host != "" {
+		env["HTTP_HOST"] = r.Host
+	}
+
+	for key, vals := range r.Header {
+		name := "HTTP_" + strings.ToUpper(strings.Replace(key, "-", "_", -1))
+
+		if name != "HTTP_PROXY" {
+			env[name] = strings.Join(vals, ", ")
+		}
+	}
+
+	return env
+}
+
+// readBody buffers a request body when its length is unknown, since
+// both protocols need the length up front.
+func readBody(r *http.Request) (io.Reader, int64, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return bytes.NewReader(nil), 0, nil
+	}
+
+	if r.ContentLength >= 0 {
+		return r.Body, r.ContentLength, nil
+	}
+
+	b, err := ioutil.ReadAll(r.Body)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return bytes.NewReader(b), int64(len(b)), nil
+}
+
+// readCGI parses a CGI response, with a Status header instead of a
+// status line, into an http.Response.
+func readCGI(r *http.Request, rc io.ReadCloser) (*http.Response, error) {
+	br := bufio.NewReader(rc)
+	header, err := textproto.NewReader(br).ReadMIMEHeader()
+
+	if err != nil && len(header) == 0 {
+		_ = rc.Close()
+		return nil, err
+	}
+
+	code := http.StatusOK
+
+	if status := header.Get("Status"); status != "" {
+		if code, err = strconv.Atoi(strings.SplitN(status, " ", 2)[0]); err != nil {
+			_ = rc.Close()
+			return nil, fmt.Errorf("invalid status: %s", status)
+		}
+
+		header.Del("Status")
+	} else if header.Get("Location") != "" {
+		code = http.StatusFound
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header(header),
+		Body:          readCloser{br, rc},
+		ContentLength: -1,
+		Request:       r,
+	}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,61 @@
+package gateway
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+func uwsgi(conn net.Conn, r *http.Request, env map[string]string, body io.Reader) (*http.Response, error) {
+	vars := []byte{}
+
+	for key, val := range env {
+		vars = uwsgiString(vars, key)
+		vars = uwsgiString(vars, val)
+	}
+
+	if len(vars) > 0xffff {
+		_ = conn.Close()
+		return nil, errors.New("uwsgi: request variables too large")
+	}
+
+	head := []byte{0, 0, 0, 0}
+	binary.LittleEndian.PutUint16(head[1:3], uint16(len(vars)))
+
+	w := bufio.NewWriter(conn)
+	_, err := w.Write(append(head, vars...))
+
+	if err == nil {
+		_, err = io.Copy(w, body)
+	}
+
+	if err == nil {
+		err = w.Flush()
+	}
+
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	res, err := http.ReadResponse(bufio.NewReader(conn), r)
+
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	res.Body = readCloser{res.Body, conn}
+
+	return res, nil
+}
+
+func uwsgiString(out []byte, s string) []byte {
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, uint16(len(s)))
+
+	return append(append(out, b...), s...)
+}
@@ -0,0 +1,96 @@
+package tmpl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var dateNames = []string{"January", "Monday", "Jan", "Mon"}
+
+// Date formats a time value with a Go layout.
+func Date(layout string, v interface{}) (string, error) {
+	return DateIn("en", layout, v)
+}
+
+// DateIn formats a time value with a Go layout, using locale names.
+func DateIn(tag string, layout string, v interface{}) (string, error) {
+	t, err := toTime(v)
+
+	if err != nil {
+		return "", err
+	}
+
+	l := locale(tag)
+	out := ""
+
+	for layout != "" {
+		name, at := nextDateName(layout)
+
+		if at == -1 {
+			out += t.Format(layout)
+			break
+		}
+
+		out += t.Format(layout[:at]) + localName(l, name, t)
+		layout = layout[at+len(name):]
+	}
+
+	return out, nil
+}
+
+func nextDateName(layout string) (string, int) {
+	name, at := "", -1
+
+	for _, n := range dateNames {
+		if i := strings.Index(layout, n); i != -1 && (at == -1 || i < at) {
+			name, at = n, i
+		}
+	}
+
+	return name, at
+}
+
+func localName(l *Locale, name string, t time.Time) string {
+	switch name {
+	case "January":
+		return l.Months[t.Month()-1]
+	case "Jan":
+		return short(l.Months[t.Month()-1])
+	case "Monday":
+		return l.Days[t.Weekday()]
+	default:
+		return short(l.Days[t.Weekday()])
+	}
+}
+
+func short(name string) string {
+	if r := []rune(name); len(r) > 3 {
+		return string(r[:3])
+	}
+
+	return name
+}
+
+func toTime(v interface{}) (time.Time, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		return *v, nil
+	case string:
+		return time.Parse(time.RFC3339, v)
+	case int:
+		return time.Unix(int64(v), 0), nil
+	case int64:
+		return time.Unix(v, 0), nil
+	case float64:
+		return time.Unix(int64(v), 0), nil
+	case json.Number:
+		n, err := v.Int64()
+		return time.Unix(n, 0), err
+	}
+
+	return time.Time{}, fmt.Errorf("date: unsupported value: %v", v)
+}
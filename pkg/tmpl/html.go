@@ -0,0 +1,146 @@
+package tmpl
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/russross/blackfriday"
+	"golang.org/x/net/html"
+)
+
+var (
+	ellipsis   = "…"
+	htmlPolicy = bluemonday.UGCPolicy()
+	voidTags   = map[string]bool{
+		"area": true, "base": true, "br": true, "col": true, "embed": true,
+		"hr": true, "img": true, "input": true, "link": true, "meta": true,
+		"source": true, "track": true, "wbr": true,
+	}
+)
+
+// TruncateHTML shortens HTML to n characters of text, keeping tags balanced.
+func TruncateHTML(n int, s interface{}) template.HTML {
+	return template.HTML(htmlPolicy.Sanitize(truncateHTML(toString(s), n, false)))
+}
+
+// TruncateWords shortens HTML to n words of text, keeping tags balanced.
+func TruncateWords(n int, s interface{}) template.HTML {
+	return template.HTML(htmlPolicy.Sanitize(truncateHTML(toString(s), n, true)))
+}
+
+// Excerpt renders markdown and shortens it to n words for listings.
+func Excerpt(n int, s interface{}) template.HTML {
+	out := blackfriday.MarkdownCommon([]byte(toString(s)))
+	return TruncateWords(n, string(out))
+}
+
+func truncateHTML(s string, n int, words bool) string {
+	z := html.NewTokenizer(strings.NewReader(s))
+	out := strings.Builder{}
+	open := []string{}
+	left := n
+
+	for done := false; !done; {
+		switch z.Next() {
+		case html.ErrorToken:
+			done = true
+		case html.TextToken:
+			text, used, cut := takeText(string(z.Text()), left, words)
+			out.WriteString(html.EscapeString(text))
+			left -= used
+
+			if cut {
+				out.WriteString(ellipsis)
+				done = true
+			}
+		case html.StartTagToken:
+			t := z.Token()
+			out.WriteString(t.String())
+
+			if !voidTags[t.Data] {
+				open = append(open, t.Data)
+			}
+		case html.EndTagToken:
+			t := z.Token()
+
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == t.Data {
+					out.WriteString(t.String())
+					open = open[:i]
+					break
+				}
+			}
+		case html.SelfClosingTagToken:
+			out.WriteString(z.Token().String())
+		}
+	}
+
+	for i := len(open) - 1; i >= 0; i-- {
+		out.WriteString("</" + open[i] + ">")
+	}
+
+	return out.String()
+}
+
+func takeText(text string, left int, words bool) (string, int, bool) {
+	if words {
+		return takeWords(text, left)
+	}
+
+	size := utf8.RuneCountInString(text)
+
+	if size <= left {
+		return text, size, false
+	}
+
+	if left <= 0 {
+		return "", 0, true
+	}
+
+	return strings.TrimRightFunc(string([]rune(text)[:left]), unicode.IsSpace), left, true
+}
+
+func takeWords(text string, left int) (string, int, bool) {
+	count, inWord := 0, false
+
+	for i, r := range text {
+		if unicode.IsSpace(r) {
+			if inWord && count == left {
+				return text[:i], count, true
+			}
+
+			inWord = false
+			continue
+		}
+
+		if !inWord {
+			if count == left {
+				return strings.TrimRightFunc(text[:i], unicode.IsSpace), count, true
+			}
+
+			count++
+			inWord = true
+		}
+	}
+
+	return text, count, false
+}
+
+func toString(s interface{}) string {
+	switch s := s.(type) {
+	case string:
+		return s
+	case template.HTML:
+		return string(s)
+	case []byte:
+		return string(s)
+	case nil:
+		return ""
+	}
+
+	return fmt.Sprintf("%v", s)
+}
@@ -0,0 +1,106 @@
+package tmpl
+
+// A Locale holds names and separators for formatting dates and numbers.
+type Locale struct {
+	Months    [12]string
+	Days      [7]string
+	Decimal   string
+	Group     string
+	Symbols   map[string]string
+	SymbolPre bool
+}
+
+var nordic = map[string]string{
+	"DKK": "kr.",
+	"EUR": "€",
+	"NOK": "kr",
+	"SEK": "kr",
+	"USD": "$",
+}
+
+// Locales holds all known locales by language tag.
+var Locales = map[string]*Locale{
+	"en": {
+		Months: [12]string{
+			"January", "February", "March", "April", "May", "June", "July",
+			"August", "September", "October", "November", "December",
+		},
+		Days: [7]string{
+			"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday",
+			"Saturday",
+		},
+		Decimal:   ".",
+		Group:     ",",
+		Symbols:   map[string]string{"EUR": "€", "GBP": "£", "USD": "$"},
+		SymbolPre: true,
+	},
+	"nb": {
+		Months: [12]string{
+			"januar", "februar", "mars", "april", "mai", "juni", "juli",
+			"august", "september", "oktober", "november", "desember",
+		},
+		Days: [7]string{
+			"søndag", "mandag", "tirsdag", "onsdag", "torsdag", "fredag",
+			"lørdag",
+		},
+		Decimal: ",",
+		Group:   " ",
+		Symbols: nordic,
+	},
+	"sv": {
+		Months: [12]string{
+			"januari", "februari", "mars", "april", "maj", "juni", "juli",
+			"augusti", "september", "oktober", "november", "december",
+		},
+		Days: [7]string{
+			"söndag", "måndag", "tisdag", "onsdag", "torsdag", "fredag",
+			"lördag",
+		},
+		Decimal: ",",
+		Group:   " ",
+		Symbols: nordic,
+	},
+	"da": {
+		Months: [12]string{
+			"januar", "februar", "marts", "april", "maj", "juni", "juli",
+			"august", "september", "oktober", "november", "december",
+		},
+		Days: [7]string{
+			"søndag", "mandag", "tirsdag", "onsdag", "torsdag", "fredag",
+			"lørdag",
+		},
+		Decimal: ",",
+		Group:   ".",
+		Symbols: nordic,
+	},
+	"fi": {
+		Months: [12]string{
+			"tammikuuta", "helmikuuta", "maaliskuuta", "huhtikuuta",
+			"toukokuuta", "kesäkuuta", "heinäkuuta", "elokuuta", "syyskuuta",
+			"lokakuuta", "marraskuuta", "joulukuuta",
+		},
+		Days: [7]string{
+			"sunnuntai", "maanantai", "tiistai", "keskiviikko", "torstai",
+			"perjantai", "lauantai",
+		},
+		Decimal: ",",
+		Group:   " ",
+		Symbols: nordic,
+	},
+}
+
+func locale(tag string) *Locale {
+	if l, ok := Locales[tag]; ok {
+		return l
+	}
+
+	for i, r := range tag {
+		if r == '-' || r == '_' {
+			if l, ok := Locales[tag[:i]]; ok {
+				return l
+			}
+		}
+	}
+
+	return Locales["en"]
+}
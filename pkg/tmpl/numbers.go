@@ -0,0 +1,110 @@
+package tmpl
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Number formats a number with grouping and fixed decimals.
+func Number(decimals int, v interface{}) (string, error) {
+	return NumberIn("en", decimals, v)
+}
+
+// NumberIn formats a number with grouping and fixed decimals for a locale.
+func NumberIn(tag string, decimals int, v interface{}) (string, error) {
+	n, err := toFloat(v)
+
+	if err != nil {
+		return "", err
+	}
+
+	return formatNumber(locale(tag), n, decimals), nil
+}
+
+// Currency formats an amount of money with a currency code.
+func Currency(code string, v interface{}) (string, error) {
+	return CurrencyIn("en", code, v)
+}
+
+// CurrencyIn formats an amount of money with a currency code for a locale.
+func CurrencyIn(tag string, code string, v interface{}) (string, error) {
+	n, err := toFloat(v)
+
+	if err != nil {
+		return "", err
+	}
+
+	l := locale(tag)
+	num := formatNumber(l, n, 2)
+	sym, ok := l.Symbols[strings.ToUpper(code)]
+
+	if !ok {
+		return strings.ToUpper(code) + " " + num, nil
+	}
+
+	if l.SymbolPre {
+		return sym + num, nil
+	}
+
+	return num + " " + sym, nil
+}
+
+func formatNumber(l *Locale, n float64, decimals int) string {
+	if decimals < 0 {
+		decimals = 0
+	}
+
+	raw := strconv.FormatFloat(math.Abs(n), 'f', decimals, 64)
+	whole, frac := raw, ""
+
+	if dot := strings.Index(raw, "."); dot != -1 {
+		whole, frac = raw[:dot], raw[dot+1:]
+	}
+
+	groups := []string{}
+
+	for len(whole) > 3 {
+		groups = append([]string{whole[len(whole)-3:]}, groups...)
+		whole = whole[:len(whole)-3]
+	}
+
+	out := strings.Join(append([]string{whole}, groups...), l.Group)
+
+	if frac != "" {
+		out += l.Decimal + frac
+	}
+
+	if n < 0 && strings.Trim(raw, "0.") != "" {
+		out = "-" + out
+	}
+
+	return out
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+
+	return 0, fmt.Errorf("number: unsupported value: %v", v)
+}
@@ -0,0 +1,27 @@
+package tmpl
+
+import (
+	"html"
+	"html/template"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+var strictPolicy = bluemonday.StrictPolicy()
+
+// Sanitize allows user-generated HTML, keeping only safe tags and
+// attributes.
+func Sanitize(s interface{}) template.HTML {
+	return template.HTML(htmlPolicy.Sanitize(toString(s)))
+}
+
+// SanitizeStrict removes all tags from HTML, keeping its escaped text.
+func SanitizeStrict(s interface{}) template.HTML {
+	return template.HTML(strictPolicy.Sanitize(toString(s)))
+}
+
+// StripTags removes all tags from HTML and returns plain text, which
+// templates escape as usual.
+func StripTags(s interface{}) string {
+	return html.UnescapeString(strictPolicy.Sanitize(toString(s)))
+}
@@ -0,0 +1,215 @@
+// Package tmpl provides HTML template helper funcs.
+package tmpl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gosimple/slug"
+)
+
+// Funcs holds all template helpers.
+var Funcs = template.FuncMap{
+	"coalesce":       Coalesce,
+	"context":        Context,
+	"currency":       Currency,
+	"currencyIn":     CurrencyIn,
+	"date":           Date,
+	"dateIn":         DateIn,
+	"default":        Default,
+	"dict":           Dict,
+	"excerpt":        Excerpt,
+	"flush":          Flush,
+	"join":           Join,
+	"jsScript":       JSScript,
+	"json":           JSON,
+	"jsonify":        Jsonify,
+	"list":           List,
+	"noescape":       Noescape,
+	"number":         Number,
+	"numberIn":       NumberIn,
+	"pluralize":      Pluralize,
+	"sanitize":       Sanitize,
+	"sanitizeStrict": SanitizeStrict,
+	"slug":           Slug,
+	"stripTags":      StripTags,
+	"title":          Title,
+	"truncate":       Truncate,
+	"truncHTML":      TruncateHTML,
+	"truncWords":     TruncateWords,
+	"when":           When,
+	"yield":          Yield,
+}
+
+// Join concatenates elements with a separator.
+func Join(s []string, sep string) string {
+	return strings.Join(s, sep)
+}
+
+// Noescape allows HTML from a plain string.
+func Noescape(s string) template.HTML {
+	return template.HTML(s)
+}
+
+// Slug formats a string to a URL-friendly format.
+func Slug(s string) string {
+	return slug.Make(s)
+}
+
+// Title capitalizes the first letter of each word.
+func Title(s string) string {
+	return strings.Title(s)
+}
+
+// When returns one alternative based on a condition.
+func When(condition bool, t, f interface{}) interface{} {
+	if condition {
+		return t
+	}
+	return f
+}
+
+// Context returns a background context; renders bind the request context.
+func Context() context.Context {
+	return context.Background()
+}
+
+// Flush returns an empty string; streamed renders flush output here.
+func Flush() string {
+	return ""
+}
+
+// Yield returns an empty HTML string.
+func Yield() template.HTML {
+	return template.HTML("")
+}
+
+// Truncate shortens a string to n characters, adding an ellipsis.
+func Truncate(n int, s string) string {
+	if n < 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+
+	return strings.TrimSpace(string([]rune(s)[:n])) + "…"
+}
+
+// Pluralize picks the singular or plural form for a count.
+func Pluralize(count interface{}, singular, plural string) string {
+	if n, err := toFloat(count); err == nil && n == 1 {
+		return singular
+	}
+
+	return plural
+}
+
+// Dict creates a map from alternating keys and values.
+func Dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("dict: odd number of arguments: %d", len(pairs))
+	}
+
+	dict := make(map[string]interface{}, len(pairs)/2)
+
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+
+		if !ok {
+			return nil, fmt.Errorf("dict: key is not a string: %v", pairs[i])
+		}
+
+		dict[key] = pairs[i+1]
+	}
+
+	return dict, nil
+}
+
+// List creates a list from its arguments.
+func List(items ...interface{}) []interface{} {
+	return items
+}
+
+// Default returns the fallback value when the given value is empty.
+func Default(fallback, value interface{}) interface{} {
+	if empty(value) {
+		return fallback
+	}
+
+	return value
+}
+
+// Coalesce returns the first non-empty value.
+func Coalesce(values ...interface{}) interface{} {
+	for _, value := range values {
+		if !empty(value) {
+			return value
+		}
+	}
+
+	return nil
+}
+
+// JSON marshals a value to a JSON string.
+func JSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// Jsonify embeds a value as JSON in a script tag with the given id, for
+// client code to read and parse.
+func Jsonify(id string, v interface{}) (template.HTML, error) {
+	b, err := json.Marshal(v)
+
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(fmt.Sprintf(
+		"<script type=\"application/json\" id=\"%s\">%s</script>",
+		template.HTMLEscapeString(id), b,
+	)), nil
+}
+
+// JSScript assigns a value to a global variable in a script tag, like
+// window.__DATA__.
+func JSScript(name string, v interface{}) (template.HTML, error) {
+	b, err := json.Marshal(v)
+
+	if err != nil {
+		return "", err
+	}
+
+	key, err := json.Marshal(name)
+
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(fmt.Sprintf("<script>window[%s] = %s;</script>", key, b)), nil
+}
+
+func empty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+
+	return rv.IsZero()
+}
@@ -0,0 +1,165 @@
+// Package tmpltest renders template strings with the helper funcs, for
+// testing funcs registered with Web.FuncMap.
+//
+// The funcs a server adds to renders (partial, cache, css, js and nonce)
+// are stubs here, not the real ones: they let templates using them parse
+// and render, but don't test them. See Stubs for what they render.
+package tmpltest
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sats-group/abc/pkg/tmpl"
+)
+
+// Nonce is what the nonce stub renders.
+const Nonce = "tmpltest-nonce"
+
+// Execute renders a template string with the helper funcs, the stubs,
+// any extra funcs and data.
+func Execute(src string, data interface{}, funcs ...template.FuncMap) (string, error) {
+	t := template.New("test")
+	t.Funcs(tmpl.Funcs).Funcs(Stubs(t))
+
+	for _, f := range funcs {
+		t.Funcs(f)
+	}
+
+	if _, err := t.Parse(src); err != nil {
+		return "", err
+	}
+
+	buf := new(bytes.Buffer)
+
+	if err := t.Execute(buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// Render renders a template string, failing the test on errors.
+func Render(t testing.TB, src string, data interface{}, funcs ...template.FuncMap) string {
+	t.Helper()
+
+	out, err := Execute(src, data, funcs...)
+
+	if err != nil {
+		t.Fatalf("render %q: %s", src, err)
+	}
+
+	return out
+}
+
+// Equal fails the test unless a template string renders want.
+func Equal(t testing.TB, want string, src string, data interface{}, funcs ...template.FuncMap) {
+	t.Helper()
+
+	if out := Render(t, src, data, funcs...); out != want {
+		t.Errorf("render %q:\n got: %q\nwant: %q", src, out, want)
+	}
+}
+
+// Contains fails the test unless a template string renders output
+// containing want.
+func Contains(t testing.TB, want string, src string, data interface{}, funcs ...template.FuncMap) {
+	t.Helper()
+
+	if out := Render(t, src, data, funcs...); !strings.Contains(out, want) {
+		t.Errorf("render %q:\n got: %q\nwant substring: %q", src, out, want)
+	}
+}
+
+// Fails fails the test unless a template string fails to render.
+func Fails(t testing.TB, src string, data interface{}, funcs ...template.FuncMap) {
+	t.Helper()
+
+	if out, err := Execute(src, data, funcs...); err == nil {
+		t.Errorf("render %q: expected an error, got %q", src, out)
+	}
+}
+
+// Stubs returns stubs of the funcs a server adds to renders of t. The
+// partial and cache stubs render templates defined in t, as "name" or
+// "partials/name", and cache nothing. The css and js stubs link each
+// source under /assets as given, without building or versioning
+// bundles, and nonce renders Nonce.
+func Stubs(t *template.Template) template.FuncMap {
+	return template.FuncMap{
+		"cache": func(key, ttl, name string, data interface{}, tags ...string) (template.HTML, error) {
+			if _, err := time.ParseDuration(ttl); err != nil {
+				return "", err
+			}
+
+			return render(t, name, data)
+		},
+		"css":   assetTags(`<link rel="stylesheet" href="%s">`, ".css"),
+		"js":    assetTags(`<script src="%s"></script>`, ".js"),
+		"nonce": func() string { return Nonce },
+		"partial": func(name string, args ...interface{}) (template.HTML, error) {
+			var data interface{}
+
+			switch len(args) {
+			case 0:
+			case 1:
+				data = args[0]
+			default:
+				d, err := tmpl.Dict(args...)
+
+				if err != nil {
+					return "", err
+				}
+
+				data = d
+			}
+
+			return render(t, name, data)
+		},
+	}
+}
+
+func render(t *template.Template, name string, data interface{}) (template.HTML, error) {
+	for _, n := range []string{name, "partials/" + name, "_partials/" + name} {
+		if t.Lookup(n) == nil {
+			continue
+		}
+
+		buf := new(bytes.Buffer)
+
+		if err := t.ExecuteTemplate(buf, n, data); err != nil {
+			return "", err
+		}
+
+		return template.HTML(buf.String()), nil
+	}
+
+	return "", fmt.Errorf("unknown template: %s", name)
+}
+
+func assetTags(format string, ext string) func(...interface{}) (template.HTML, error) {
+	return func(sources ...interface{}) (template.HTML, error) {
+		out := ""
+
+		for _, source := range sources {
+			s, ok := source.(string)
+
+			if !ok {
+				return "", fmt.Errorf("invalid asset source: %v", source)
+			}
+
+			if path.Ext(s) != ext {
+				s += ext
+			}
+
+			out += fmt.Sprintf(format, template.HTMLEscapeString(path.Join("/assets", s)))
+		}
+
+		return template.HTML(out), nil
+	}
+}
@@ -0,0 +1,43 @@
+package tmpltest
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+)
+
+func TestExecute(t *testing.T) {
+	funcs := template.FuncMap{"shout": strings.ToUpper}
+
+	tests := []struct {
+		src  string
+		data interface{}
+		want string
+		err  bool
+	}{
+		{src: `{{.}}`, data: "<b>", want: "&lt;b&gt;"},
+		{src: `{{shout .}}`, data: "hi", want: "HI"},
+		{src: `{{define "partials/nav"}}<nav>{{.}}</nav>{{end}}{{partial "nav" "home"}}`, want: "<nav>home</nav>"},
+		{src: `{{define "nav"}}{{.a}}-{{.b}}{{end}}{{partial "nav" "a" 1 "b" 2}}`, want: "1-2"},
+		{src: `{{partial "missing"}}`, err: true},
+		{src: `{{define "box"}}[{{.}}]{{end}}{{cache "k" "1m" "box" "x" "tag"}}`, want: "[x]"},
+		{src: `{{define "box"}}{{end}}{{cache "k" "soon" "box" nil}}`, err: true},
+		{src: `{{css "site" "print.css"}}`, want: `<link rel="stylesheet" href="/assets/site.css"><link rel="stylesheet" href="/assets/print.css">`},
+		{src: `{{js "app"}}`, want: `<script src="/assets/app.js"></script>`},
+		{src: `<script nonce="{{nonce}}"></script>`, want: `<script nonce="` + Nonce + `"></script>`},
+		{src: `{{`, err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Execute(tt.src, tt.data, funcs)
+
+		if (err != nil) != tt.err {
+			t.Errorf("Execute(%q) error = %v, want error %v", tt.src, err, tt.err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("Execute(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/codegangsta/negroni"
+)
+
+const (
+	// ampVariant names the lightweight variant of a template, so
+	// page.amp.html is the AMP variant of page.html.
+	ampVariant = "amp"
+
+	// ampSuffix requests the AMP variant of a route, as does ?amp.
+	ampSuffix = "/" + ampVariant
+)
+
+type ampKey struct{}
+
+func (w *Web) newAMP() Middleware {
+	if !w.config.AMP {
+		return nil
+	}
+
+	fn := func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		_, flag := r.URL.Query()[ampVariant]
+
+		if p := strings.TrimSuffix(r.URL.Path, ampSuffix); p != r.URL.Path && w.engine.ampRoute(orDefault(p, "/")) {
+			r.URL.Path, flag = orDefault(p, "/"), true
+		}
+
+		if flag {
+			r = r.WithContext(context.WithValue(r.Context(), ampKey{}, true))
+		}
+
+		next(rw, r)
+	}
+
+	return negroni.HandlerFunc(fn)
+}
+
+// ampVariants picks the AMP variants of a template and the layout for
+// requests asking for them, when there are any.
+func (e *engine) ampVariants(r *http.Request, file string, env Env) string {
+	if amp, _ := r.Context().Value(ampKey{}).(bool); !amp {
+		return file
+	}
+
+	if _, ok := env[layoutKey]; !ok && e.config.layout() != "" {
+		if layout := e.ampFile(e.config.layout()); e.hasTemplate(layout) {
+			env[layoutKey] = layout
+		}
+	}
+
+	if alt := e.ampFile(file); e.hasTemplate(alt) {
+		return alt
+	}
+
+	return file
+}
+
+// ampRoute reports if a path has an AMP variant, so that only those
+// routes lose an /amp suffix.
+func (e *engine) ampRoute(p string) bool {
+	for _, file := range []string{e.expandPath(p), e.expandPath(strings.TrimSuffix(p, "/") + "/")} {
+		if e.hasTemplate(e.ampFile(file)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *engine) ampFile(file string) string {
+	ext := filepath.Ext(file)
+
+	if ext == "" {
+		ext = e.config.frontendExt()
+	}
+
+	return strings.TrimSuffix(file, filepath.Ext(file)) + "." + ampVariant + ext
+}
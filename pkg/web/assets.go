@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"html/template"
 	"io"
-	"log"
 	"mime"
 	"net/http"
 	"path"
@@ -14,26 +13,41 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/microcosm-cc/bluemonday"
-	"github.com/sats-group/abc/internal/files"
 	"github.com/russross/blackfriday"
+	"github.com/sats-group/abc/internal/files"
 )
 
 type assets struct {
-	prod bool
-	dir  string
-	root string
-
-	cache map[string]*assetCache
+	prod    bool
+	dir     string
+	root    string
+	version string
+	prefix  string
+	stats   *counters
+	stores  []assetStore
+
+	inlineLimit int64
+
+	mu      sync.RWMutex
+	cache   map[string]*assetCache
+	names   map[string]string
+	busts   map[string]*assetCache
+	modules map[string]bool
+
+	integrity  map[string]string
+	buildHooks []func(string, int, time.Duration)
 }
 
 type assetType struct {
-	name string
-	ext  string
-	html string
-	proc func([]byte) []byte
+	name  string
+	ext   string
+	html  string
+	proc  func([]byte) []byte
+	build func(paths []string) ([]byte, error)
 }
 
 type assetCache struct {
@@ -42,14 +56,18 @@ type assetCache struct {
 	time  time.Time
 	paths []string
 	bytes []byte
+
+	gzip   []byte
+	brotli []byte
 }
 
-type assetFunc func(sources ...interface{}) template.HTML
+type assetFunc func(sources ...interface{}) (template.HTML, error)
 
 var (
-	concatFile = "file"
-	concatRoot = rooted("/assets")
-	htmlPolicy = bluemonday.UGCPolicy()
+	concatFile    = "file"
+	concatVersion = "version"
+	concatRoot    = "/assets"
+	htmlPolicy    = bluemonday.UGCPolicy()
 )
 
 var paste = &assetType{
@@ -83,158 +101,395 @@ var js = &assetType{
 
 func (w *Web) newAssets() *assets {
 	a := &assets{
-		prod:  w.config.prod(),
-		dir:   w.config.dir(),
-		root:  w.config.frontendPath(),
-		cache: map[string]*assetCache{},
+		prod:    w.config.prod(),
+		dir:     w.config.dir(),
+		root:    w.config.frontendPath(),
+		version: w.config.assetVersion(),
+		stats:   w.stats,
+		cache:   map[string]*assetCache{},
+		names:   map[string]string{},
+		busts:   map[string]*assetCache{},
+		modules: map[string]bool{},
+
+		inlineLimit: w.config.CSSInlineLimit,
+		integrity:   w.config.Integrity,
 	}
 
+	a.prefix = rooted(concatRoot, a.version)
+	a.stores = w.newAssetStores(a.version)
+
 	f := template.FuncMap{
 		paste.name: a.inlined(paste),
 		tpl.name:   a.inlined(tpl),
 		md.name:    a.inlined(md),
-		css.name:   a.file(css),
-		js.name:    a.file(js),
+		css.name:   a.external(css, a.file(css)),
+		js.name:    a.external(js, a.file(js)),
+		"asset":    a.assetURL,
+		"font":     a.font,
 	}
 
 	if a.prod {
-		f[css.name] = a.combined(css)
-		f[js.name] = a.combined(js)
+		f[css.name] = a.external(css, a.combined(css))
+		f[js.name] = a.external(js, a.combined(js))
+	}
+
+	if a.prod && w.config.TplBundle != "" {
+		f[tpl.name] = a.combined(a.tplBundle(w.config.TplBundle))
 	}
 
-	w.Handler("get", concatRoot+"*"+concatFile, a)
+	w.Handler("get", concatRoot+"/:"+concatVersion+"/*"+concatFile, a)
 	w.FuncMap(f)
 
 	return a
 }
 
 func (a *assets) ServeHTTP(rw http.ResponseWriter, r *http.Request, p Params) {
-	filename := p.Wildcard(concatFile)
-	file, ok := a.cache[filename]
+	filename, version := p.Wildcard(concatFile), p.Get(concatVersion)
+
+	if !validAsset(version, filename) {
+		http404(rw, r)
+		return
+	}
+
+	file, ok := a.cached(filename)
+
+	if version != a.version {
+		if stored, found := a.storedVersion(version, filename); found {
+			file, ok = stored, true
+		}
+	} else if !ok {
+		file, ok = a.stored(filename)
+	}
 
 	if !ok {
 		http404(rw, r)
 		return
 	}
 
-	reader := bytes.NewReader(file.bytes)
+	encoding, b := file.encoded(r)
+	reader := bytes.NewReader(b)
 	rw.Header().Set("content-type", file.mime)
+
+	// Bundles requested under another build's prefix may differ from
+	// what that build served, so they are never cached for long.
+	if a.prod && version == a.version {
+		rw.Header().Set("Cache-Control", immutable)
+	} else if a.prod {
+		rw.Header().Set("Cache-Control", "no-cache")
+	}
+
+	if file.gzip != nil {
+		rw.Header().Add("Vary", "Accept-Encoding")
+	}
+
+	if encoding != "" {
+		rw.Header().Set("Content-Encoding", encoding)
+	}
+
 	http.ServeContent(rw, r, filename, file.time, reader)
 }
 
 func (a *assets) combined(t *assetType) assetFunc {
-	return func(sources ...interface{}) template.HTML {
+	return func(sources ...interface{}) (template.HTML, error) {
 		pack := a.unpackPaths(sources)
-		file := a.combosFromPaths(t, pack)
-		href := concatRoot + file.name
 
-		if strings.HasPrefix(pack[0], "/") {
-			href = path.Join(a.root, href)
-		} else {
-			href = strings.TrimPrefix(href, "/")
+		if len(pack) == 0 {
+			return "", fmt.Errorf("%s: no sources given", t.name)
+		}
+
+		groups, types := [][]string{pack}, []*assetType{t}
+
+		if t == css {
+			resolved, err := a.resolvePaths(pack)
+
+			if err != nil {
+				return "", err
+			}
+
+			light, dark := a.splitDark(resolved)
+			groups, types = [][]string{light, dark}, []*assetType{css, darkCSS}
 		}
 
-		return template.HTML(fmt.Sprintf(t.html, href, ""))
+		// Modules are bundled on their own, with their imports rewritten.
+		if t == js {
+			resolved, err := a.resolvePaths(pack)
+
+			if err != nil {
+				return "", err
+			}
+
+			classic, modules := a.splitModules(resolved)
+			groups, types = [][]string{classic}, []*assetType{js}
+
+			for _, rel := range modules {
+				groups, types = append(groups, []string{rel}), append(types, moduleJS)
+			}
+		}
+
+		tags := []string{}
+
+		for i, group := range groups {
+			if len(group) == 0 {
+				continue
+			}
+
+			file, err := a.combosFromPaths(types[i], group)
+
+			if err != nil {
+				return "", err
+			}
+
+			html := types[i].html
+			href := a.prefix + file.name
+
+			if strings.HasPrefix(group[0], "/") {
+				href = path.Join(a.root, href)
+			} else {
+				href = strings.TrimPrefix(href, "/")
+			}
+
+			tags = append(tags, fmt.Sprintf(html, href, ""))
+		}
+
+		return template.HTML(strings.Join(tags, "\n")), nil
 	}
 }
 
-func (a *assets) file(t *assetType) func(...interface{}) template.HTML {
-	return func(sources ...interface{}) template.HTML {
-		files := a.resolvePaths(a.unpackPaths(sources))
+func (a *assets) file(t *assetType) assetFunc {
+	return func(sources ...interface{}) (template.HTML, error) {
+		files, err := a.resolvePaths(a.unpackPaths(sources))
+
+		if err != nil {
+			return "", err
+		}
+
+		dark := []string{}
+
+		if t == css {
+			files, dark = a.splitDark(files)
+		}
 
 		for i, rel := range files {
-			files[i] = fmt.Sprintf(t.html, path.Join(a.root, rel), "")
+			html := t.html
+
+			if t == js && a.module(rel) {
+				html = moduleJS.html
+			}
+
+			files[i] = fmt.Sprintf(html, path.Join(a.root, rel), "")
 		}
 
-		return template.HTML(strings.Join(files, "\n"))
+		for _, rel := range dark {
+			files = append(files, fmt.Sprintf(darkCSS.html, path.Join(a.root, rel), ""))
+		}
+
+		return template.HTML(strings.Join(files, "\n")), nil
 	}
 }
 
 func (a *assets) inlined(t *assetType) assetFunc {
-	return func(sources ...interface{}) template.HTML {
+	return func(sources ...interface{}) (template.HTML, error) {
 		tags := []string{}
 
-		files := a.resolvePaths(a.unpackPaths(sources))
-		items := a.inlinedFromPaths(t, files)
+		files, err := a.resolvePaths(a.unpackPaths(sources))
+
+		if err != nil {
+			return "", err
+		}
+
+		items, err := a.inlinedFromPaths(t, files)
+
+		if err != nil {
+			return "", err
+		}
 
 		for _, res := range items {
 			src := path.Join(a.root, res.name)
 			tags = append(tags, fmt.Sprintf(t.html, src, string(res.bytes[:])))
 		}
 
-		return template.HTML(strings.Join(tags, "\n"))
+		return template.HTML(strings.Join(tags, "\n")), nil
 	}
 }
 
-func (a *assets) combosFromPaths(t *assetType, paths []string) *assetCache {
-	name := hash(strings.Join(paths, "")) + t.ext
+// combosFromPaths builds a bundle of paths, named by a hash of its
+// content so its URL changes with it. Prod servers build each bundle
+// once, remembering its name by the paths it was built from.
+func (a *assets) combosFromPaths(t *assetType, paths []string) (*assetCache, error) {
+	seed, build := strings.Join(paths, ""), a.bytesFromPaths
 
-	if cached, ok := a.cache[name]; ok && a.prod {
-		return cached
+	if t.build != nil {
+		seed, build = t.name+seed, t.build
 	}
 
-	b := a.bytesFromPaths(paths)
+	if cached, ok := a.bundled(seed); ok && a.prod {
+		a.stats.asset(true)
+		return cached, nil
+	}
+
+	start := time.Now()
+	a.stats.asset(false)
+	b, err := build(paths)
+
+	if err != nil {
+		return nil, err
+	}
 
 	if t.proc != nil {
 		b = t.proc(b)
 	}
 
-	a.cache[name] = &assetCache{
-		name:  name,
+	file := &assetCache{
+		name:  hash(string(b)) + t.ext,
 		mime:  mime.TypeByExtension(t.ext),
 		time:  time.Now(),
 		paths: paths,
 		bytes: b,
 	}
 
-	return a.cache[name]
+	if a.prod {
+		if err := file.precompress(); err != nil {
+			return nil, err
+		}
+	}
+
+	a.built(file, time.Since(start))
+
+	a.mu.Lock()
+	a.names[seed] = file.name
+	a.mu.Unlock()
+
+	return file, nil
+}
+
+// bundled returns the bundle built from a seed before.
+func (a *assets) bundled(seed string) (*assetCache, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	file, ok := a.cache[a.names[seed]]
+	return file, ok
+}
+
+func (a *assets) cached(name string) (*assetCache, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	file, ok := a.cache[name]
+	return file, ok
+}
+
+// files returns a snapshot of the cached files by name.
+func (a *assets) files() map[string]*assetCache {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	files := make(map[string]*assetCache, len(a.cache))
+
+	for name, file := range a.cache {
+		files[name] = file
+	}
+
+	return files
 }
 
-func (a *assets) inlinedFromPaths(t *assetType, paths []string) []*assetCache {
+func (a *assets) put(file *assetCache) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.cache[file.name] = file
+}
+
+// built caches a newly built file and runs the asset build hooks.
+func (a *assets) built(file *assetCache, took time.Duration) {
+	a.mu.Lock()
+	old := a.cache[file.name]
+	a.cache[file.name] = file
+	a.mu.Unlock()
+
+	a.stats.bundled(old, file, took)
+	a.keep(file)
+
+	for _, fn := range a.buildHooks {
+		fn(file.name, len(file.bytes), took)
+	}
+}
+
+func (a *assets) inlinedFromPaths(t *assetType, paths []string) ([]*assetCache, error) {
 	contents := []*assetCache{}
 
 	for _, name := range paths {
-		contents = append(contents, a.inlinedFromPath(t, name))
+		content, err := a.inlinedFromPath(t, name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		contents = append(contents, content)
 	}
 
-	return contents
+	return contents, nil
 }
 
-func (a *assets) inlinedFromPath(t *assetType, name string) *assetCache {
-	if cached, ok := a.cache[name]; ok && a.prod {
-		return cached
+func (a *assets) inlinedFromPath(t *assetType, name string) (*assetCache, error) {
+	if cached, ok := a.cached(name); ok && a.prod {
+		a.stats.asset(true)
+		return cached, nil
 	}
 
-	b := a.bytesFromPaths([]string{name})
+	start := time.Now()
+	a.stats.asset(false)
+	b, err := a.bytesFromPaths([]string{name})
+
+	if err != nil {
+		return nil, err
+	}
 
 	if t.proc != nil {
 		b = t.proc(b)
 	}
 
-	a.cache[name] = &assetCache{name: name, bytes: b}
-	return a.cache[name]
+	file := &assetCache{name: name, bytes: b}
+	a.built(file, time.Since(start))
+
+	return file, nil
 }
 
-func (a *assets) bytesFromPaths(paths []string) []byte {
+func (a *assets) bytesFromPaths(paths []string) ([]byte, error) {
 	hfs := http.Dir(a.dir)
 	buf := bytes.NewBuffer(nil)
+	files, err := a.resolvePaths(paths)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range files {
+		if err := a.bufferImports(hfs, name, buf); err != nil {
+			return nil, err
+		}
+	}
 
-	for _, name := range a.resolvePaths(paths) {
-		a.bufferFromPath(hfs, name, buf)
+	if len(files) > 0 && path.Ext(files[0]) == css.ext {
+		return hoistImports(buf.Bytes()), nil
 	}
 
-	return buf.Bytes()
+	return buf.Bytes(), nil
 }
 
-func (a *assets) bufferFromPath(dir http.FileSystem, rel string, w io.Writer) {
-	if f, err := dir.Open(rel); err == nil {
-		if _, err = io.Copy(w, f); err != nil {
-			log.Fatalln(err)
-		}
-		if err := f.Close(); err != nil {
-			log.Fatalln(err)
-		}
+func (a *assets) bufferFromPath(dir http.FileSystem, rel string, w io.Writer) error {
+	f, err := dir.Open(rel)
+
+	if err != nil {
+		return fmt.Errorf("unknown asset: %s", rel)
 	}
+
+	if _, err = io.Copy(w, f); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
 }
 
 func (a *assets) unpackPaths(sources []interface{}) []string {
@@ -254,18 +509,28 @@ func (a *assets) unpackPaths(sources []interface{}) []string {
 	return files
 }
 
-func (a *assets) resolvePaths(sources []string) []string {
+func (a *assets) resolvePaths(sources []string) ([]string, error) {
 	files := []string{}
 
 	for _, source := range sources {
-		files = append(files, a.resolvePath(source)...)
+		resolved, err := a.resolvePath(source)
+
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, resolved...)
 	}
 
-	return files
+	return files, nil
 }
 
-func (a *assets) resolvePath(source string) []string {
-	files := files.List(filepath.Join(a.dir, source))
+func (a *assets) resolvePath(source string) ([]string, error) {
+	files, err := files.ListE(filepath.Join(a.dir, source))
+
+	if err != nil {
+		return nil, fmt.Errorf("unknown asset: %s", source)
+	}
 
 	for i, abs := range files {
 		if rel, err := filepath.Rel(a.dir, abs); err == nil {
@@ -273,7 +538,7 @@ func (a *assets) resolvePath(source string) []string {
 		}
 	}
 
-	return files
+	return files, nil
 }
 
 func (a *assets) prefixPath(source string, rel string) string {
@@ -296,8 +561,8 @@ func hash(seed string) string {
 	return fmt.Sprintf("%x", sha1.Sum([]byte(seed)))[:12]
 }
 
-func rooted(root string) string {
-	return fmt.Sprintf("%s/%s/", root, hash(timestamp()))
+func rooted(root string, version string) string {
+	return fmt.Sprintf("%s/%s/", root, version)
 }
 
 func timestamp() string {
@@ -0,0 +1,143 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"regexp"
+	"time"
+)
+
+// assetStorePrefix namespaces bundles shared through the CacheStore.
+const assetStorePrefix = "asset:"
+
+// An assetStore keeps built bundles beyond the process, so their URLs
+// keep working after restarts and on other instances.
+type assetStore interface {
+	load(version string, name string) (*assetCache, bool)
+	store(file *assetCache)
+}
+
+// A sharedStore keeps bundles in the CacheStore, so instances behind a
+// load balancer serve each other's bundle URLs.
+type sharedStore struct {
+	cache   CacheStore
+	version string
+	ttl     time.Duration
+}
+
+// assetNamePattern matches bundle names, a content hash with an
+// extension.
+var assetNamePattern = regexp.MustCompile(`^[0-9a-f]{12}\.[A-Za-z0-9]+$`)
+
+// assetVersionPattern matches asset versions, which are hashes too.
+var assetVersionPattern = regexp.MustCompile(`^[0-9a-f]{12}$`)
+
+type sharedAsset struct {
+	Mime   string   `json:"mime"`
+	Paths  []string `json:"paths"`
+	Sum    string   `json:"sum"`
+	Bytes  []byte   `json:"bytes"`
+	Gzip   []byte   `json:"gzip,omitempty"`
+	Brotli []byte   `json:"brotli,omitempty"`
+}
+
+func (w *Web) newAssetStores(version string) []assetStore {
+	stores := []assetStore{}
+
+	if d := w.newDiskCache(version); d != nil {
+		stores = append(stores, d)
+	}
+
+	if w.config.AssetStore && w.config.prod() {
+		stores = append(stores, &sharedStore{cache: w.store, version: version, ttl: w.config.assetCacheTTL()})
+	}
+
+	return stores
+}
+
+func (s *sharedStore) key(version string, name string) string {
+	return assetStorePrefix + version + "/" + name
+}
+
+func (s *sharedStore) load(version string, name string) (*assetCache, bool) {
+	b, ok := s.cache.Get(s.key(version, name))
+
+	if !ok {
+		return nil, false
+	}
+
+	entry := &sharedAsset{}
+
+	if err := json.Unmarshal(b, entry); err != nil || checksum(entry.Bytes) != entry.Sum {
+		log.Println("asset store: invalid entry:", name)
+		s.cache.Purge(s.key(version, name))
+		return nil, false
+	}
+
+	return &assetCache{
+		name:   name,
+		mime:   entry.Mime,
+		time:   time.Now(),
+		paths:  entry.Paths,
+		bytes:  entry.Bytes,
+		gzip:   entry.Gzip,
+		brotli: entry.Brotli,
+	}, true
+}
+
+func (s *sharedStore) store(file *assetCache) {
+	b, err := json.Marshal(&sharedAsset{
+		Mime:   file.mime,
+		Paths:  file.paths,
+		Sum:    checksum(file.bytes),
+		Bytes:  file.bytes,
+		Gzip:   file.gzip,
+		Brotli: file.brotli,
+	})
+
+	if err != nil {
+		log.Println("asset store:", err)
+		return
+	}
+
+	s.cache.Set(s.key(s.version, file.name), b, s.ttl)
+}
+
+// stored loads a bundle of this build from the asset stores into memory.
+func (a *assets) stored(name string) (*assetCache, bool) {
+	file, ok := a.storedVersion(a.version, name)
+
+	if ok {
+		a.put(file)
+	}
+
+	return file, ok
+}
+
+// storedVersion loads a bundle of any build from the asset stores.
+func (a *assets) storedVersion(version string, name string) (*assetCache, bool) {
+	if !validAsset(version, name) {
+		return nil, false
+	}
+
+	for _, s := range a.stores {
+		if file, ok := s.load(version, name); ok {
+			return file, true
+		}
+	}
+
+	return nil, false
+}
+
+// validAsset reports if a requested version and bundle name are safe
+// to look up in the stores, which use them in paths and keys.
+func validAsset(version string, name string) bool {
+	return assetNamePattern.MatchString(name) && assetVersionPattern.MatchString(version)
+}
+
+// keep saves a built bundle to the asset stores.
+func (a *assets) keep(file *assetCache) {
+	for _, s := range a.stores {
+		s.store(file)
+	}
+}
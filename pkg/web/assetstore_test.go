@@ -0,0 +1,59 @@
+package web
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestSharedStore(t *testing.T) {
+	const version = "0123456789ab"
+
+	cache := NewMemoryStore()
+	s := &sharedStore{cache: cache, version: version, ttl: time.Hour}
+	a := &assets{version: version, stores: []assetStore{s}}
+
+	s.store(&assetCache{name: "ba9876543210.css", mime: "text/css", paths: []string{"site.css"}, bytes: []byte("p{}")})
+
+	file, ok := a.storedVersion(version, "ba9876543210.css")
+
+	if !ok || !bytes.Equal(file.bytes, []byte("p{}")) || file.mime != "text/css" {
+		t.Fatalf("stored bundle not loaded: %v %v", file, ok)
+	}
+
+	if _, ok := a.storedVersion("ffffffffffff", "ba9876543210.css"); ok {
+		t.Error("bundle loaded for another version")
+	}
+
+	cache.Set(s.key(version, "ffffffffffff.js"), []byte(`{"sum":"bad","bytes":"eCgp"}`), time.Hour)
+
+	if _, ok := a.storedVersion(version, "ffffffffffff.js"); ok {
+		t.Error("bundle with a bad checksum loaded")
+	}
+
+	if _, ok := cache.Get(s.key(version, "ffffffffffff.js")); ok {
+		t.Error("bundle with a bad checksum kept")
+	}
+}
+
+func TestValidAsset(t *testing.T) {
+	invalid := [][2]string{
+		{"0123456789ab", "0123456789ab.min.js"},
+		{"0123456789ab", "0123456789AB.css"},
+		{"0123456789ab", "../../etc/passwd"},
+		{"0123456789ab", "0123456789ab.css/x"},
+		{"0123456789ab", "0123456789ab.css\n"},
+		{"../0123456789", "0123456789ab.css"},
+		{"", "0123456789ab.css"},
+	}
+
+	for _, c := range invalid {
+		if validAsset(c[0], c[1]) {
+			t.Errorf("validAsset(%q, %q) accepted", c[0], c[1])
+		}
+	}
+
+	if !validAsset("0123456789ab", "ba9876543210.js") {
+		t.Error("valid asset rejected")
+	}
+}
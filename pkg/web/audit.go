@@ -0,0 +1,88 @@
+package web
+
+import (
+	"html/template"
+	"log"
+	"strings"
+	"text/template/parse"
+)
+
+// unsafeFuncs lists template funcs that bypass contextual escaping.
+var unsafeFuncs = map[string]bool{
+	"noescape": true,
+}
+
+// escapeFunc replaces noescape in strict mode, leaving strings to the
+// template's own escaping.
+func escapeFunc(s string) string {
+	return s
+}
+
+func (e *engine) audit(root *template.Template, name string, rel string) {
+	if !e.config.Audit || e.config.prod() {
+		return
+	}
+
+	for _, t := range root.Templates() {
+		if t.Tree == nil || t.Tree.ParseName != name {
+			continue
+		}
+
+		auditNode(t.Tree.Root, func(n *parse.IdentifierNode) {
+			loc, _ := t.Tree.ErrorContext(n)
+			loc = rel + strings.TrimPrefix(loc, name)
+
+			if e.audited[loc] {
+				return
+			}
+
+			e.audited[loc] = true
+			log.Printf("audit: %s: %s bypasses escaping\n", loc, n.Ident)
+		})
+	}
+}
+
+func auditNode(node parse.Node, fn func(*parse.IdentifierNode)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+
+		for _, c := range n.Nodes {
+			auditNode(c, fn)
+		}
+	case *parse.ActionNode:
+		auditNode(n.Pipe, fn)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+
+		for _, c := range n.Cmds {
+			auditNode(c, fn)
+		}
+	case *parse.CommandNode:
+		for _, c := range n.Args {
+			auditNode(c, fn)
+		}
+	case *parse.IdentifierNode:
+		if unsafeFuncs[n.Ident] {
+			fn(n)
+		}
+	case *parse.IfNode:
+		auditBranch(&n.BranchNode, fn)
+	case *parse.RangeNode:
+		auditBranch(&n.BranchNode, fn)
+	case *parse.WithNode:
+		auditBranch(&n.BranchNode, fn)
+	case *parse.TemplateNode:
+		auditNode(n.Pipe, fn)
+	}
+}
+
+func auditBranch(b *parse.BranchNode, fn func(*parse.IdentifierNode)) {
+	auditNode(b.Pipe, fn)
+	auditNode(b.List, fn)
+	auditNode(b.ElseList, fn)
+}
@@ -1,21 +1,44 @@
 package web
 
 import (
+	"context"
+	"crypto/subtle"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
+)
 
-	"github.com/goji/httpauth"
+const (
+	authDelay   = 250 * time.Millisecond
+	authMaxWait = 5 * time.Second
+	authLockout = 15 * time.Minute
 )
 
 type auth struct {
+	realm    string
+	limit    int
 	patterns []string
 	matchers []*matcher
+	skips    []string
+	networks []*net.IPNet
+	proxies  []*net.IPNet
+
+	mu       sync.Mutex
+	failures map[string]*failure
 }
 
 type matcher struct {
-	handler func(http.Handler) http.Handler
 	pattern string
+	users   map[string]string
+}
+
+type failure struct {
+	count int
+	last  time.Time
 }
 
 func (w *Web) newAuth() Middleware {
@@ -23,8 +46,22 @@ func (w *Web) newAuth() Middleware {
 		return nil
 	}
 
-	a := &auth{patterns: w.config.Auth}
+	a := &auth{
+		realm:    w.config.authRealm(),
+		limit:    w.config.authLimit(),
+		patterns: w.config.Auth,
+		failures: map[string]*failure{},
+	}
+
 	a.matchers = a.parsePatterns(a.patterns)
+	a.skips = a.parseSkips(w.config.AuthSkip)
+	a.networks = a.parseNetworks(w.config.AuthTrust)
+	a.proxies = a.parseNetworks(w.config.authProxy())
+
+	w.Every(authLockout, func(context.Context) error {
+		a.prune(time.Now())
+		return nil
+	})
 
 	return a
 }
@@ -35,58 +72,269 @@ func (a *auth) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.Hand
 		return
 	}
 
-	if handler := a.matchingHandler(r.URL.RequestURI()); handler != nil {
-		handler(next).ServeHTTP(rw, r)
+	m := a.matchingMatcher(r.URL.RequestURI())
+	ip := a.clientIP(r)
+
+	if m == nil || a.skipped(r.URL.Path) || a.trusted(peerIP(r)) {
+		next(rw, r)
 		return
 	}
 
-	next(rw, r)
+	if a.locked(ip) {
+		http429(rw, r)
+		return
+	}
+
+	if user, pass, ok := r.BasicAuth(); ok && m.allows(user, pass) {
+		a.succeed(ip)
+		next(rw, r)
+		return
+	}
+
+	time.Sleep(a.fail(ip))
+	rw.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", a.realm))
+	http401(rw, r)
 }
 
-func (a *auth) matchingHandler(uri string) func(http.Handler) http.Handler {
+func (a *auth) matchingMatcher(uri string) *matcher {
 	source := strings.TrimPrefix(uri, "/")
 
 	for _, m := range a.matchers {
 		if strings.HasPrefix(source, m.pattern) {
-			return m.handler
+			return m
 		}
 	}
 
 	return nil
 }
 
+func (a *auth) skipped(path string) bool {
+	source := strings.TrimPrefix(path, "/")
+
+	for _, skip := range a.skips {
+		if source == skip || strings.HasPrefix(source, strings.TrimSuffix(skip, "/")+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *auth) trusted(ip string) bool {
+	return contains(a.networks, ip)
+}
+
+func contains(networks []*net.IPNet, ip string) bool {
+	addr := net.ParseIP(ip)
+
+	if addr == nil {
+		return false
+	}
+
+	for _, network := range networks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clientIP keys lockouts on the connection's peer, or on the client
+// address it forwarded when it's a trusted proxy, so clients can't get
+// around them by claiming other addresses.
+func (a *auth) clientIP(r *http.Request) string {
+	peer := peerIP(r)
+
+	if contains(a.proxies, peer) {
+		return hostIP(r.RemoteAddr)
+	}
+
+	return peer
+}
+
+// peerIP returns the address of the connection's peer, which unlike the
+// client address can't be claimed in forwarding headers.
+func peerIP(r *http.Request) string {
+	if peer, ok := r.Context().Value(peerKey{}).(string); ok {
+		return hostIP(peer)
+	}
+
+	return hostIP(r.RemoteAddr)
+}
+
+func hostIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+
+	if err != nil {
+		return addr
+	}
+
+	return host
+}
+
+func (a *auth) locked(ip string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, ok := a.failures[ip]
+
+	return ok && f.count >= a.limit && time.Since(f.last) < authLockout
+}
+
+func (a *auth) succeed(ip string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.failures, ip)
+}
+
+func (a *auth) fail(ip string) time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	f, ok := a.failures[ip]
+
+	if !ok {
+		f = &failure{}
+		a.failures[ip] = f
+	}
+
+	f.count++
+	f.last = now
+
+	if wait := time.Duration(f.count-1) * authDelay; wait < authMaxWait {
+		return wait
+	}
+
+	return authMaxWait
+}
+
+// prune forgets failures older than the lockout.
+func (a *auth) prune(now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for key, f := range a.failures {
+		if now.Sub(f.last) >= authLockout {
+			delete(a.failures, key)
+		}
+	}
+}
+
 func (a *auth) parsePatterns(patterns []string) []*matcher {
 	matchers := []*matcher{}
+	byPattern := map[string]*matcher{}
+
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+
+		user, pass, path := a.parsePattern(pattern)
 
-	for _, pattern := range a.patterns {
-		if pattern != "" {
-			if matcher := a.parsePattern(pattern); matcher != nil {
-				matchers = append(matchers, matcher)
-			}
+		if m, ok := byPattern[path]; ok {
+			m.users[user] = pass
+			continue
 		}
+
+		m := &matcher{pattern: path, users: map[string]string{user: pass}}
+		byPattern[path] = m
+		matchers = append(matchers, m)
 	}
 
 	return matchers
 }
 
-func (a *auth) parsePattern(pattern string) *matcher {
-	colon := strings.LastIndex(pattern, ":")
+func (a *auth) parsePattern(pattern string) (string, string, string) {
+	user, pass, path, err := splitAuthPattern(pattern)
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	return user, pass, path
+}
+
+func splitAuthPattern(pattern string) (string, string, string, error) {
 	alpha := strings.LastIndex(pattern, "@")
 
 	if alpha == -1 {
-		alpha = len(pattern) - 1
+		alpha = len(pattern)
 	}
 
+	colon := strings.Index(pattern[:alpha], ":")
+
 	if colon == -1 || len(pattern) < 3 {
-		log.Fatalf("invalid auth pattern: %s\n", pattern)
+		return "", "", "", fmt.Errorf("invalid auth pattern: %s", pattern)
 	}
 
 	user := pattern[:colon]
 	pass := pattern[colon+1 : alpha]
-	path := pattern[alpha+1:]
+	path := ""
 
-	return &matcher{
-		handler: httpauth.SimpleBasicAuth(user, pass),
-		pattern: strings.TrimPrefix(path, "/"),
+	if alpha < len(pattern) {
+		path = pattern[alpha+1:]
 	}
+
+	return user, pass, strings.TrimPrefix(path, "/"), nil
+}
+
+func (a *auth) parseSkips(paths []string) []string {
+	skips := []string{}
+
+	for _, path := range paths {
+		if path = strings.TrimPrefix(path, "/"); path != "" {
+			skips = append(skips, path)
+		}
+	}
+
+	return skips
+}
+
+func (a *auth) parseNetworks(cidrs []string) []*net.IPNet {
+	networks := []*net.IPNet{}
+
+	for _, cidr := range cidrs {
+		network, err := parseNetwork(cidr)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks
+}
+
+func parseNetwork(cidr string) (*net.IPNet, error) {
+	if !strings.Contains(cidr, "/") {
+		if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+			cidr += "/32"
+		} else {
+			cidr += "/128"
+		}
+	}
+
+	_, network, err := net.ParseCIDR(cidr)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth network: %s", cidr)
+	}
+
+	return network, nil
+}
+
+func (m *matcher) allows(user, pass string) bool {
+	ok := 0
+
+	for u, p := range m.users {
+		userOK := subtle.ConstantTimeCompare([]byte(user), []byte(u))
+		passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(p))
+		ok |= userOK & passOK
+	}
+
+	return ok == 1
 }
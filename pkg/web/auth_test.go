@@ -0,0 +1,51 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthBypass(t *testing.T) {
+	a := &auth{realm: "test", limit: 10, failures: map[string]*failure{}}
+	a.matchers = a.parsePatterns([]string{"user:pass"})
+	a.skips = a.parseSkips([]string{"/healthz", "/status/"})
+	a.networks = a.parseNetworks([]string{"10.0.0.0/8", "192.168.1.5", "::1"})
+
+	tests := []struct {
+		name   string
+		path   string
+		remote string
+		peer   string
+		status int
+	}{
+		{"skipped path", "/healthz", "203.0.113.7:1", "", http.StatusOK},
+		{"below skipped dir", "/status/db", "203.0.113.7:1", "", http.StatusOK},
+		{"sibling of skipped path", "/healthzx", "203.0.113.7:1", "", http.StatusUnauthorized},
+		{"trusted network", "/", "10.1.2.3:1", "", http.StatusOK},
+		{"trusted address", "/", "192.168.1.5:1", "", http.StatusOK},
+		{"trusted ipv6", "/", "[::1]:1", "", http.StatusOK},
+		{"untrusted address", "/", "192.168.1.6:1", "", http.StatusUnauthorized},
+		{"forwarded from trusted", "/", "10.1.2.3:1", "203.0.113.7:1", http.StatusUnauthorized},
+		{"trusted peer", "/", "203.0.113.7:1", "10.0.0.1:1", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.path, nil)
+			r.RemoteAddr = tt.remote
+
+			if tt.peer != "" {
+				r = r.WithContext(context.WithValue(r.Context(), peerKey{}, tt.peer))
+			}
+
+			rec := httptest.NewRecorder()
+			a.ServeHTTP(rec, r, func(rw http.ResponseWriter, r *http.Request) {})
+
+			if rec.Code != tt.status {
+				t.Errorf("got %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package web
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Balancing policies for upstreams with several members.
+const (
+	RoundRobin = "round-robin"
+	LeastConn  = "least-conn"
+)
+
+const (
+	ejectFailures = 3
+	ejectDuration = 30 * time.Second
+)
+
+// A pool spreads requests over the members of an upstream, ejecting
+// members for a while after repeated failures.
+type pool struct {
+	policy  string
+	sticky  string
+	cookies *cookies
+	next    uint64
+
+	mu      sync.RWMutex
+	members []*member
+}
+
+type member struct {
+	base   string
+	active int64
+
+	mu    sync.Mutex
+	fails int
+	until time.Time
+}
+
+func newPool(policy string, sticky string, bases []string) *pool {
+	p := &pool{policy: policy, sticky: sticky}
+
+	for _, base := range bases {
+		p.members = append(p.members, &member{base: base})
+	}
+
+	return p
+}
+
+func (p *pool) pick(rw http.ResponseWriter, r *http.Request) *member {
+	p.mu.RLock()
+	members := p.members
+	p.mu.RUnlock()
+
+	if len(members) == 0 {
+		return nil
+	}
+
+	healthy := []*member{}
+
+	for _, m := range members {
+		if m.healthy() {
+			healthy = append(healthy, m)
+		}
+	}
+
+	if len(healthy) == 0 {
+		healthy = members
+	}
+
+	if m := p.stuck(r, healthy); m != nil {
+		return m
+	}
+
+	m := p.choose(healthy)
+
+	if p.sticky != "" && len(members) > 1 && rw != nil && p.cookies != nil {
+		p.cookies.set(rw, &http.Cookie{Name: p.sticky, HttpOnly: true}, hash(m.base))
+	}
+
+	return m
+}
+
+// update replaces the members of a pool, keeping the state of members
+// that are still present.
+func (p *pool) update(bases []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	known := map[string]*member{}
+
+	for _, m := range p.members {
+		known[m.base] = m
+	}
+
+	members := []*member{}
+
+	for _, base := range bases {
+		if m, ok := known[base]; ok {
+			members = append(members, m)
+		} else {
+			members = append(members, &member{base: base})
+		}
+	}
+
+	p.members = members
+}
+
+func (p *pool) stuck(r *http.Request, healthy []*member) *member {
+	if p.sticky == "" {
+		return nil
+	}
+
+	c, err := r.Cookie(p.sticky)
+
+	if err != nil {
+		return nil
+	}
+
+	for _, m := range healthy {
+		if hash(m.base) == c.Value {
+			return m
+		}
+	}
+
+	return nil
+}
+
+func (p *pool) choose(healthy []*member) *member {
+	if p.policy == LeastConn {
+		best := healthy[0]
+
+		for _, m := range healthy[1:] {
+			if atomic.LoadInt64(&m.active) < atomic.LoadInt64(&best.active) {
+				best = m
+			}
+		}
+
+		return best
+	}
+
+	n := atomic.AddUint64(&p.next, 1)
+
+	return healthy[int((n-1)%uint64(len(healthy)))]
+}
+
+func (m *member) acquire() {
+	atomic.AddInt64(&m.active, 1)
+}
+
+func (m *member) release() {
+	atomic.AddInt64(&m.active, -1)
+}
+
+func (m *member) healthy() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return time.Now().After(m.until)
+}
+
+// report records the outcome of a request, ejecting the member when
+// it keeps failing.
+func (m *member) report(ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if ok {
+		m.fails = 0
+		return
+	}
+
+	if m.fails++; m.fails >= ejectFailures {
+		m.fails = 0
+		m.until = time.Now().Add(ejectDuration)
+	}
+}
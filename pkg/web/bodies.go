@@ -0,0 +1,97 @@
+package web
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/codegangsta/negroni"
+)
+
+// A BodyRule restricts request bodies sent to paths under a prefix.
+// Types lists accepted media types (like "application/json" or
+// "image/*") and Size is the maximum body size in bytes.
+type BodyRule struct {
+	Path  string
+	Types []string
+	Size  int64
+}
+
+func (w *Web) newBodies() Middleware {
+	rules := w.config.Bodies
+
+	if len(rules) == 0 {
+		return nil
+	}
+
+	fn := func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		rule := matchBodyRule(rules, r.URL.Path)
+
+		if rule == nil || !hasBody(r) {
+			next(rw, r)
+			return
+		}
+
+		if !rule.accepts(r.Header.Get("Content-Type")) {
+			http415(rw, r)
+			return
+		}
+
+		if rule.Size > 0 {
+			if r.ContentLength > rule.Size {
+				http413(rw, r)
+				return
+			}
+
+			r.Body = http.MaxBytesReader(rw, r.Body, rule.Size)
+		}
+
+		next(rw, r)
+	}
+
+	return negroni.HandlerFunc(fn)
+}
+
+func matchBodyRule(rules []BodyRule, path string) *BodyRule {
+	var match *BodyRule
+
+	for i, rule := range rules {
+		if !matchPath(rule.Path, path) {
+			continue
+		}
+
+		if match == nil || len(rule.Path) > len(match.Path) {
+			match = &rules[i]
+		}
+	}
+
+	return match
+}
+
+func (b *BodyRule) accepts(contentType string) bool {
+	if len(b.Types) == 0 {
+		return true
+	}
+
+	media, _, err := mime.ParseMediaType(contentType)
+
+	if err != nil {
+		return false
+	}
+
+	for _, t := range b.Types {
+		if t == media || t == "*/*" {
+			return true
+		}
+
+		if strings.HasSuffix(t, "/*") && strings.HasPrefix(media, strings.TrimSuffix(t, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasBody(r *http.Request) bool {
+	return r.ContentLength > 0 || len(r.TransferEncoding) > 0
+}
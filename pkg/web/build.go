@@ -0,0 +1,67 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+
+	"github.com/sats-group/abc/internal/files"
+)
+
+// A Manifest maps asset bundle URLs to their source files.
+type Manifest map[string][]string
+
+// BuildAssets renders every page of a site in prod to build its asset
+// bundles into outDir, with names that depend only on the sources. A
+// server with AssetCache set to outDir serves them without building,
+// so it can run on a read-only filesystem.
+func BuildAssets(dir string, outDir string, opts ...Option) (Manifest, error) {
+	opts = append(opts, WithDir(dir), WithProd(), WithConfig(func(c *Config) { c.AssetCache = outDir }))
+
+	w := New(opts...)
+	pages, err := w.engine.pages()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rel := range pages {
+		url := "/" + strings.TrimSuffix(filepath.ToSlash(rel), "index"+w.config.frontendExt())
+		rec := httptest.NewRecorder()
+		w.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+
+		if rec.Code != http.StatusOK {
+			return nil, fmt.Errorf("build error: %s (%d)", url, rec.Code)
+		}
+	}
+
+	manifest := w.assets.manifest()
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := files.Write(filepath.Join(outDir, w.assets.version, AssetManifest), b); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// manifest lists the bundles built so far.
+func (a *assets) manifest() Manifest {
+	manifest := Manifest{}
+
+	for name, file := range a.files() {
+		if file.paths != nil {
+			manifest[a.prefix+name] = file.paths
+		}
+	}
+
+	return manifest
+}
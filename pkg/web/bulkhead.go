@@ -0,0 +1,73 @@
+package web
+
+import (
+	"context"
+	"time"
+)
+
+const defaultQueueWait = time.Second
+
+// A bulkhead caps the requests in flight to an upstream, so one slow
+// backend can't hold every goroutine and buffer. A few more requests
+// may queue for a free slot; the rest are shed.
+type bulkhead struct {
+	slots chan struct{}
+	queue chan struct{}
+	wait  time.Duration
+}
+
+func newBulkhead(max int, queue int, wait time.Duration) *bulkhead {
+	if max <= 0 {
+		return nil
+	}
+
+	if wait <= 0 {
+		wait = defaultQueueWait
+	}
+
+	return &bulkhead{
+		slots: make(chan struct{}, max),
+		queue: make(chan struct{}, queue),
+		wait:  wait,
+	}
+}
+
+// enter takes a slot, queueing for one if allowed. It reports false when
+// the request should be shed.
+func (b *bulkhead) enter(ctx context.Context) bool {
+	if b == nil {
+		return true
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	select {
+	case b.queue <- struct{}{}:
+	default:
+		return false
+	}
+
+	defer func() { <-b.queue }()
+
+	timer := time.NewTimer(b.wait)
+	defer timer.Stop()
+
+	select {
+	case b.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (b *bulkhead) leave() {
+	if b != nil {
+		<-b.slots
+	}
+}
@@ -0,0 +1,107 @@
+package web
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const immutable = "public, max-age=31536000, immutable"
+
+// assetURL links any static file, like an image or font. In prod the
+// file is served from the asset root under a name hashed from its
+// content, so it can be cached for good. URLs are returned as given.
+func (a *assets) assetURL(source string) (string, error) {
+	if isExternal(source) {
+		return source, nil
+	}
+
+	if !a.prod {
+		if _, err := a.stat(source); err != nil {
+			return "", err
+		}
+
+		return path.Join(a.root, source), nil
+	}
+
+	file, err := a.busted(source)
+
+	if err != nil {
+		return "", err
+	}
+
+	href := a.prefix + file.name
+
+	if strings.HasPrefix(source, "/") {
+		return path.Join(a.root, href), nil
+	}
+
+	return strings.TrimPrefix(href, "/"), nil
+}
+
+func (a *assets) busted(source string) (*assetCache, error) {
+	a.mu.RLock()
+	cached, ok := a.busts[source]
+	a.mu.RUnlock()
+
+	if ok {
+		a.stats.asset(true)
+		return cached, nil
+	}
+
+	start := time.Now()
+	a.stats.asset(false)
+	info, err := a.stat(source)
+
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := a.bytesFromPaths([]string{source})
+
+	if err != nil {
+		return nil, err
+	}
+
+	ext := path.Ext(source)
+	file := &assetCache{
+		name:  hash(string(b)) + ext,
+		mime:  mime.TypeByExtension(ext),
+		time:  info.ModTime(),
+		paths: []string{source},
+		bytes: b,
+	}
+
+	a.stats.bundled(nil, file, time.Since(start))
+
+	a.mu.Lock()
+	a.cache[file.name] = file
+	a.busts[source] = file
+	a.mu.Unlock()
+
+	a.keep(file)
+
+	return file, nil
+}
+
+func (a *assets) stat(source string) (interface{ ModTime() time.Time }, error) {
+	f, err := http.Dir(a.dir).Open(filepath.ToSlash(source))
+
+	if err != nil {
+		return nil, fmt.Errorf("unknown asset: %s", source)
+	}
+
+	defer f.Close()
+
+	info, err := f.Stat()
+
+	if err != nil || info.IsDir() {
+		return nil, fmt.Errorf("unknown asset: %s", source)
+	}
+
+	return info, nil
+}
@@ -0,0 +1,155 @@
+package web
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A CacheStore holds rendered fragments and other shared entries, with
+// invalidation by key or by tag. It may be backed by an external store
+// to share entries between instances.
+type CacheStore interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration, tags ...string)
+	Purge(keys ...string)
+	PurgeTag(tags ...string)
+}
+
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	tags    map[string]map[string]bool
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+	tags    []string
+}
+
+// NewMemoryStore creates an in-process CacheStore. A server using it
+// drops expired entries in the background.
+func NewMemoryStore() CacheStore {
+	return &memoryStore{
+		entries: map[string]*memoryEntry{},
+		tags:    map[string]map[string]bool{},
+	}
+}
+
+// storeSweep is how often expired entries are dropped from a memory
+// store, as entries not read again are never removed on access.
+const storeSweep = time.Minute
+
+func (w *Web) newStore() CacheStore {
+	store := w.config.Cache
+
+	if store == nil {
+		store = NewMemoryStore()
+	}
+
+	if m, ok := store.(*memoryStore); ok {
+		w.Every(storeSweep, func(context.Context) error {
+			m.sweep()
+			return nil
+		})
+	}
+
+	return store
+}
+
+// PurgeTag removes cached entries carrying any of the given tags.
+func (w *Web) PurgeTag(tags ...string) {
+	w.store.PurgeTag(tags...)
+}
+
+func (s *memoryStore) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+
+	if !ok {
+		return nil, false
+	}
+
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		s.remove(key)
+		return nil, false
+	}
+
+	return e.value, true
+}
+
+func (s *memoryStore) Set(key string, value []byte, ttl time.Duration, tags ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(key)
+	e := &memoryEntry{value: value, tags: tags}
+
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+
+	s.entries[key] = e
+
+	for _, tag := range tags {
+		if s.tags[tag] == nil {
+			s.tags[tag] = map[string]bool{}
+		}
+
+		s.tags[tag][key] = true
+	}
+}
+
+func (s *memoryStore) Purge(keys ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		s.remove(key)
+	}
+}
+
+func (s *memoryStore) PurgeTag(tags ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range s.tags[tag] {
+			s.remove(key)
+		}
+	}
+}
+
+func (s *memoryStore) sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	for key, e := range s.entries {
+		if !e.expires.IsZero() && now.After(e.expires) {
+			s.remove(key)
+		}
+	}
+}
+
+func (s *memoryStore) remove(key string) {
+	e, ok := s.entries[key]
+
+	if !ok {
+		return
+	}
+
+	for _, tag := range e.tags {
+		delete(s.tags[tag], key)
+
+		if len(s.tags[tag]) == 0 {
+			delete(s.tags, tag)
+		}
+	}
+
+	delete(s.entries, key)
+}
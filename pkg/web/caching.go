@@ -0,0 +1,128 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// A CacheRule sets the Cache-Control of decorated pages under a path,
+// instead of deriving it from the backend response.
+type CacheRule struct {
+	Path    string
+	Control string
+}
+
+// Backend headers passed through to decorated pages.
+var cacheHeaders = []string{"Cache-Control", "Expires", "Last-Modified"}
+
+// Headers set by the nocache middleware, replaced by a caching policy.
+var nocacheHeaders = []string{"Cache-Control", "Expires", "Pragma", "X-Accel-Expires"}
+
+// cacheHeaders derives caching headers for a decorated page from a rule
+// or the backend response, varying on what the page may vary on. Pages
+// that may be personal are only cached privately.
+func (p *proxy) cacheHeaders(rw http.ResponseWriter, r *http.Request, res *http.Response) {
+	control := res.Header.Get("Cache-Control")
+	rule := false
+
+	for _, cr := range p.config.CacheRules {
+		if matchPath(cr.Path, r.URL.Path) {
+			control, rule = cr.Control, true
+		}
+	}
+
+	if control == "" {
+		return
+	}
+
+	h := rw.Header()
+
+	for _, key := range nocacheHeaders {
+		h.Del(key)
+	}
+
+	for _, key := range cacheHeaders {
+		if val := res.Header.Get(key); val != "" && (!rule || key == "Last-Modified") {
+			h.Set(key, val)
+		}
+	}
+
+	for _, key := range p.config.vary(r) {
+		h.Add("Vary", key)
+	}
+
+	if personal(r, res) {
+		control = privateControl(control)
+	}
+
+	h.Set("Cache-Control", control)
+}
+
+// personal reports if a page may differ per client: backend responses
+// setting cookies, and requests with credentials or cookies, which
+// carry sessions and flash messages for renders and env funcs to use.
+// Pages with a CSP nonce are unique to their request too.
+func personal(r *http.Request, res *http.Response) bool {
+	if res.Header.Get("Set-Cookie") != "" || Nonce(r.Context()) != "" {
+		return true
+	}
+
+	return r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != ""
+}
+
+// privateControl keeps shared caches from storing a response, replacing
+// directives for them with private unless it isn't stored at all.
+func privateControl(control string) string {
+	kept := []string{}
+
+	for _, d := range strings.Split(control, ",") {
+		d = strings.TrimSpace(d)
+		name := strings.ToLower(strings.TrimSpace(strings.SplitN(d, "=", 2)[0]))
+
+		switch name {
+		case "":
+		case "private", "no-store":
+			return control
+		case "public", "s-maxage", "proxy-revalidate":
+		default:
+			kept = append(kept, d)
+		}
+	}
+
+	return strings.Join(append([]string{"private"}, kept...), ", ")
+}
+
+// uncache drops caching headers from a response that failed to render.
+func uncache(rw http.ResponseWriter) {
+	for _, key := range cacheHeaders {
+		rw.Header().Del(key)
+	}
+}
+
+// etag sets a weak ETag for a cacheable rendered page and reports if the
+// client already has it.
+func etag(rw http.ResponseWriter, r *http.Request, status int, out *bytes.Buffer) bool {
+	control := rw.Header().Get("Cache-Control")
+
+	if status != http.StatusOK || control == "" || strings.Contains(control, "no-store") {
+		return false
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	tag := fmt.Sprintf("W/\"%x\"", sha1.Sum(out.Bytes()))
+	rw.Header().Set("ETag", tag)
+
+	for _, match := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		if m := strings.TrimSpace(match); m == tag || m == "*" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,125 @@
+package web
+
+import (
+	"context"
+	"html/template"
+	"math/rand"
+	"net/http"
+
+	"github.com/codegangsta/negroni"
+)
+
+// Template sets a request can be pinned to with the candidate header or
+// cookie.
+const (
+	currentSet   = "current"
+	candidateSet = "candidate"
+)
+
+type candidateKey struct{}
+
+// newCandidate creates a second engine whose templates in the candidate
+// dir replace those of the same name, for rolling out template changes.
+func (e *engine) newCandidate() *engine {
+	funcs := template.FuncMap{}
+
+	for k, v := range e.funcs {
+		funcs[k] = v
+	}
+
+	return &engine{
+		config:  e.config,
+		funcs:   funcs,
+		store:   e.store,
+		cookies: e.cookies,
+		stats:   e.stats,
+		audited: map[string]bool{},
+		overlay: e.config.Candidate,
+
+		nonceMark: e.nonceMark,
+	}
+}
+
+// pick chooses the engine for the template set of a request.
+func (e *engine) pick(ctx context.Context) *engine {
+	if e.candidate != nil && isCandidate(ctx) {
+		return e.candidate
+	}
+
+	return e
+}
+
+func isCandidate(ctx context.Context) bool {
+	use, _ := ctx.Value(candidateKey{}).(bool)
+	return use
+}
+
+// newCandidateSet picks the template set of each request: one named in
+// the candidate header, then in the candidate cookie, otherwise a sticky
+// random pick of CandidatePercent of clients.
+func (w *Web) newCandidateSet() Middleware {
+	if w.config.Candidate == "" {
+		return nil
+	}
+
+	fn := func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		use := w.config.useCandidate(rw, r)
+		next(rw, r.WithContext(context.WithValue(r.Context(), candidateKey{}, use)))
+	}
+
+	return negroni.HandlerFunc(fn)
+}
+
+func (c *Config) useCandidate(rw http.ResponseWriter, r *http.Request) bool {
+	switch r.Header.Get(c.candidateHeader()) {
+	case candidateSet:
+		return true
+	case currentSet:
+		return false
+	}
+
+	if cookie, err := r.Cookie(c.candidateCookie()); err == nil {
+		switch cookie.Value {
+		case candidateSet:
+			return true
+		case currentSet:
+			return false
+		}
+	}
+
+	if c.CandidatePercent <= 0 {
+		return false
+	}
+
+	use, set := rand.Intn(100) < c.CandidatePercent, currentSet
+
+	if use {
+		set = candidateSet
+	}
+
+	http.SetCookie(rw, &http.Cookie{
+		Name:     c.candidateCookie(),
+		Value:    set,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return use
+}
+
+func (c *Config) candidateHeader() string {
+	if c.CandidateHeader == "" {
+		return "X-Templates"
+	}
+
+	return c.CandidateHeader
+}
+
+func (c *Config) candidateCookie() string {
+	if c.CandidateCookie == "" {
+		return "templates"
+	}
+
+	return c.CandidateCookie
+}
@@ -0,0 +1,73 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+// isExternal reports whether an asset source is a URL, like a library
+// on a CDN, rather than a local file.
+func isExternal(source string) bool {
+	return strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "//")
+}
+
+// external lets an asset func take URLs among its sources. They get a
+// tag of their own, with any configured integrity hash, and the local
+// files between them are handled by fn, keeping the given order.
+func (a *assets) external(t *assetType, fn assetFunc) assetFunc {
+	return func(sources ...interface{}) (template.HTML, error) {
+		pack := a.unpackPaths(sources)
+		tags, local := []string{}, []interface{}{}
+
+		flush := func() error {
+			if len(local) == 0 {
+				return nil
+			}
+
+			html, err := fn(local...)
+			tags, local = append(tags, string(html)), []interface{}{}
+
+			return err
+		}
+
+		for _, source := range pack {
+			if !isExternal(source) {
+				local = append(local, source)
+				continue
+			}
+
+			if err := flush(); err != nil {
+				return "", err
+			}
+
+			tags = append(tags, a.externalTag(t, source))
+		}
+
+		if err := flush(); err != nil {
+			return "", err
+		}
+
+		if len(pack) == 0 {
+			return fn()
+		}
+
+		return template.HTML(strings.Join(tags, "\n")), nil
+	}
+}
+
+func (a *assets) externalTag(t *assetType, url string) string {
+	attrs := ""
+
+	if sum, ok := a.integrity[url]; ok {
+		attrs = fmt.Sprintf(" integrity=\"%s\" crossorigin=\"anonymous\"", template.HTMLEscapeString(sum))
+	}
+
+	url = template.HTMLEscapeString(url)
+
+	if t == css {
+		return fmt.Sprintf("<link rel=\"stylesheet\" href=\"%s\"%s>", url, attrs)
+	}
+
+	return fmt.Sprintf("<script src=\"%s\"%s></script>", url, attrs)
+}
@@ -0,0 +1,88 @@
+package web
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// Windows-1252 differs from ISO-8859-1 only in 0x80-0x9F, where it has
+// printable characters instead of control codes.
+var cp1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8d, 'Ž', 0x8f,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9d, 'ž', 'Ÿ',
+}
+
+// Charsets decoded to UTF-8. Browsers treat ISO-8859-1 as Windows-1252,
+// and so do we.
+var legacyCharsets = map[string]bool{
+	"iso-8859-1":   true,
+	"iso8859-1":    true,
+	"latin1":       true,
+	"l1":           true,
+	"windows-1252": true,
+	"cp1252":       true,
+}
+
+// latinReader turns Windows-1252 bytes into UTF-8.
+type latinReader struct {
+	r   io.Reader
+	buf [512]byte
+	out []byte
+}
+
+func (t *latinReader) Read(p []byte) (int, error) {
+	for len(t.out) == 0 {
+		n, err := t.r.Read(t.buf[:])
+
+		for _, b := range t.buf[:n] {
+			t.out = appendRune(t.out, decode1252(b))
+		}
+
+		if len(t.out) == 0 && err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, t.out)
+	t.out = t.out[n:]
+
+	return n, nil
+}
+
+func decode1252(b byte) rune {
+	if b >= 0x80 && b < 0xa0 {
+		return cp1252[b-0x80]
+	}
+
+	return rune(b)
+}
+
+func appendRune(out []byte, r rune) []byte {
+	var enc [utf8.UTFMax]byte
+	n := utf8.EncodeRune(enc[:], r)
+
+	return append(out, enc[:n]...)
+}
+
+// transcode decodes a body declared as a legacy Latin charset to UTF-8,
+// since JSON decoding and templates assume UTF-8.
+func transcode(res *http.Response) {
+	kind, params, err := mime.ParseMediaType(res.Header.Get(contentTypeKey))
+
+	if err != nil || !legacyCharsets[strings.ToLower(params["charset"])] {
+		return
+	}
+
+	res.Body = &struct {
+		io.Reader
+		io.Closer
+	}{&latinReader{r: res.Body}, res.Body}
+
+	params["charset"] = "utf-8"
+	res.Header.Set(contentTypeKey, mime.FormatMediaType(kind, params))
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+}
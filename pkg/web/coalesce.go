@@ -0,0 +1,157 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A flight collapses identical in-flight backend requests into a single
+// call, sharing the buffered response with every waiting client.
+type flight struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	done chan struct{}
+	res  *sharedResponse
+	err  error
+}
+
+type sharedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// coalesceTimeout bounds shared calls for requests without a deadline.
+const coalesceTimeout = 30 * time.Second
+
+func newFlight() *flight {
+	return &flight{calls: map[string]*call{}}
+}
+
+// do calls fn once for concurrent callers of the same key, reporting
+// to callers if they joined a call already in flight.
+func (f *flight) do(ctx context.Context, key string, fn func() (*sharedResponse, error)) (*sharedResponse, bool, error) {
+	f.mu.Lock()
+	c, ok := f.calls[key]
+
+	if !ok {
+		c = &call{done: make(chan struct{})}
+		f.calls[key] = c
+
+		go func() {
+			c.res, c.err = fn()
+
+			f.mu.Lock()
+			delete(f.calls, key)
+			f.mu.Unlock()
+
+			close(c.done)
+		}()
+	}
+
+	f.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.res, ok, c.err
+	case <-ctx.Done():
+		return nil, ok, ctx.Err()
+	}
+}
+
+// coalesced performs a GET once for all concurrent identical requests.
+// Requests whose clients sent credentials go on their own, as do ones
+// joining a call whose response sets cookies or is too large to share.
+// The shared call is detached from any single client's cancellation,
+// not its deadline.
+func (p *proxy) coalesced(up *upstream, req *http.Request, r *http.Request) (*http.Response, error) {
+	if r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" {
+		return up.client.Do(req)
+	}
+
+	shared, joined, err := p.flight.do(req.Context(), flightKey(req), func() (*sharedResponse, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), coalesceTimeout)
+
+		if deadline, ok := req.Context().Deadline(); ok {
+			cancel()
+			ctx, cancel = context.WithDeadline(context.Background(), deadline)
+		}
+
+		defer cancel()
+
+		res, err := up.client.Do(req.WithContext(ctx))
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer res.Body.Close()
+
+		if res.ContentLength > maxDecoded {
+			return nil, errTooLarge
+		}
+
+		body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxDecoded+1))
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(body) > maxDecoded {
+			return nil, errTooLarge
+		}
+
+		return &sharedResponse{status: res.StatusCode, header: res.Header, body: body}, nil
+	})
+
+	if err == errTooLarge || (err == nil && joined && shared.header.Get("Set-Cookie") != "") {
+		return up.client.Do(req)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:        http.StatusText(shared.status),
+		StatusCode:    shared.status,
+		Header:        shared.header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(shared.body)),
+		ContentLength: int64(len(shared.body)),
+		Request:       req,
+	}, nil
+}
+
+// flightKey identifies requests sharing a response by their method, URL
+// and headers, leaving out the signature headers that change over time.
+func flightKey(req *http.Request) string {
+	keys := []string{}
+
+	for key := range req.Header {
+		if key != signTimeHeader && !strings.HasPrefix(key, "X-Signature") {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	b := &strings.Builder{}
+	b.WriteString(req.Method + " " + req.URL.String())
+
+	for _, key := range keys {
+		fmt.Fprintf(b, "\n%s: %q", key, req.Header[key])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,176 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Fetch is one backend request merged into a composed page. Its Path
+// may refer to route params as :name, and the decoded JSON is put in
+// the template env under Name. Optional fetches may fail without
+// failing the page.
+type Fetch struct {
+	Name     string
+	Path     string
+	Timeout  time.Duration
+	Optional bool
+}
+
+// Client headers passed on to fetches, so pages can be per user.
+var fetchHeaders = []string{"Cookie", "Accept-Language"}
+
+type fetchResult struct {
+	name string
+	data interface{}
+	err  error
+}
+
+// Compose adds a route rendering a template with data from several
+// backend requests, fetched concurrently.
+func (w *Web) Compose(method string, path string, file string, fetches ...Fetch) {
+	if w.proxy == nil {
+		log.Fatalf("compose requires a backend: %s\n", path)
+	}
+
+	tmpl := strings.TrimPrefix(file, "/")
+
+	if filepath.Ext(tmpl) == "" {
+		tmpl += w.config.backendExt()
+	}
+
+	w.HandlerFunc(method, path, func(rw http.ResponseWriter, r *http.Request, p Params) {
+		w.proxy.compose(rw, r, p, tmpl, fetches)
+	})
+}
+
+func (p *proxy) compose(rw http.ResponseWriter, r *http.Request, params Params, tmpl string, fetches []Fetch) {
+	results := make([]fetchResult, len(fetches))
+	wg := sync.WaitGroup{}
+
+	for i, f := range fetches {
+		wg.Add(1)
+
+		go func(i int, f Fetch) {
+			defer wg.Done()
+
+			data, err := p.fetch(r, f.Timeout, expandParams(f.Path, params))
+			results[i] = fetchResult{name: f.Name, data: data, err: err}
+		}(i, f)
+	}
+
+	wg.Wait()
+
+	data := Env{}
+	failed := []string{}
+
+	for i, res := range results {
+		if res.err != nil && r.Context().Err() != nil {
+			return
+		}
+
+		if res.err != nil && !fetches[i].Optional {
+			log.Println(res.name, res.err)
+			http502(rw, r)
+			return
+		}
+
+		if res.err != nil {
+			log.Println(res.name, res.err)
+			failed = append(failed, res.name)
+		}
+
+		data[res.name] = res.data
+	}
+
+	data[responseKey] = Env{"failed": failed}
+	p.engine.respond(rw, r, http.StatusOK, tmpl, data)
+}
+
+func (p *proxy) fetch(r *http.Request, timeout time.Duration, path string) (interface{}, error) {
+	ctx := r.Context()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	base, report := p.backendURL(r)
+	url := base + strings.TrimPrefix(path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range fetchHeaders {
+		if val := r.Header.Get(key); val != "" {
+			req.Header.Set(key, val)
+		}
+	}
+
+	if err := p.signBackend(req); err != nil {
+		return nil, err
+	}
+
+	p.identity(req)
+	res, err := p.do(req)
+	report(res, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		return nil, fmt.Errorf("backend responded %d: %s", res.StatusCode, path)
+	}
+
+	var data interface{}
+
+	if err := p.decodeResponse(res, &data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// expandParams fills in route params, escaped so they stay within their
+// path segments. Catch-all params are cleaned of dot segments first.
+func expandParams(p string, params Params) string {
+	parts := strings.Split(p, "/")
+
+	for i, part := range parts {
+		switch {
+		case strings.HasPrefix(part, ":"):
+			parts[i] = escapeSegment(params.Get(part[1:]))
+		case strings.HasPrefix(part, "*"):
+			segments := strings.Split(strings.TrimPrefix(path.Clean("/"+params.Wildcard(part[1:])), "/"), "/")
+
+			for j, segment := range segments {
+				segments[j] = escapeSegment(segment)
+			}
+
+			parts[i] = strings.Join(segments, "/")
+		}
+	}
+
+	return strings.Join(parts, "/")
+}
+
+func escapeSegment(s string) string {
+	if s == "." || s == ".." {
+		return strings.Replace(s, ".", "%2E", -1)
+	}
+
+	return url.PathEscape(s)
+}
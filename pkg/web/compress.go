@@ -0,0 +1,71 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// precompress stores gzip and brotli variants of a bundle, so serving it
+// costs no compression per request.
+func (f *assetCache) precompress() error {
+	gz := new(bytes.Buffer)
+	zw, err := gzip.NewWriterLevel(gz, gzip.BestCompression)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := zw.Write(f.bytes); err != nil {
+		return err
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	br := new(bytes.Buffer)
+	bw := brotli.NewWriterLevel(br, brotli.BestCompression)
+
+	if _, err := bw.Write(f.bytes); err != nil {
+		return err
+	}
+
+	if err := bw.Close(); err != nil {
+		return err
+	}
+
+	f.gzip, f.brotli = gz.Bytes(), br.Bytes()
+
+	return nil
+}
+
+// encoded picks the best precompressed variant the client accepts.
+func (f *assetCache) encoded(r *http.Request) (string, []byte) {
+	accepted := map[string]bool{}
+
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		q := ""
+
+		if len(fields) > 1 {
+			q = strings.TrimSpace(fields[1])
+		}
+
+		if q != "q=0" && q != "q=0.0" {
+			accepted[strings.ToLower(fields[0])] = true
+		}
+	}
+
+	switch {
+	case f.brotli != nil && accepted["br"]:
+		return "br", f.brotli
+	case f.gzip != nil && accepted["gzip"]:
+		return "gzip", f.gzip
+	default:
+		return "", f.bytes
+	}
+}
@@ -3,11 +3,13 @@ package web
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/url"
-	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sats-group/abc/internal/files"
 )
@@ -16,14 +18,113 @@ import (
 type Config struct {
 	Frontend string
 	Backend  string
-
-	Dir    string
-	JSON   []string
-	Layout string
-	Auth   []string
-	Proxy  bool
-	Prod   bool
-	Debug  bool
+	Backends []string
+	Balance  string
+	Sticky   string
+	Resolver Resolver
+	Refresh  time.Duration
+	Signing  *Signing
+	Coalesce bool
+
+	MaxInFlight int
+	MaxQueue    int
+	QueueWait   time.Duration
+	BufferLimit int64
+
+	StripAcceptEncoding bool
+
+	UpstreamTimeout time.Duration
+	RenderTimeout   time.Duration
+	RequestTimeout  time.Duration
+	Timeouts        []TimeoutRule
+
+	Dir     string
+	JSON    []string
+	Layout  string
+	Proxy   bool
+	Prod    bool
+	Staging bool
+	Debug   bool
+	Stream  bool
+	Secret  string
+	AMP     bool
+	Audit   bool
+	Strict  bool
+
+	TemplateDirs []string
+
+	DevServer      string
+	DevServerPaths []string
+
+	Candidate        string
+	CandidateHeader  string
+	CandidateCookie  string
+	CandidatePercent int
+
+	CSP      string
+	CSPNonce bool
+
+	PurgePath  string
+	PurgeToken string
+
+	NotFound string
+	Suggest  bool
+	Errors   map[string]string
+	Unwrap   string
+	GraphQL  string
+
+	TplBundle      string
+	CSSInlineLimit int64
+	AssetVersion   string
+	AssetCache     string
+	AssetCacheTTL  time.Duration
+	AssetStore     bool
+	Integrity      map[string]string
+
+	Descriptors string
+	Upstreams   []Upstream
+
+	Auth      []string
+	AuthRealm string
+	AuthLimit int
+	AuthSkip  []string
+	AuthTrust []string
+	AuthProxy []string
+
+	Bodies     []BodyRule
+	Headers    []HeaderRule
+	CacheRules []CacheRule
+	Forms      []FormRule
+	JSONRules  []JSONRule
+	Routes     []RouteRule
+	StaticDeny []string
+	Indexes    []IndexRule
+	Languages  []string
+	Variants   []string
+
+	ThemeCookie string
+	Icon        string
+	IconDir     string
+	AppName     string
+
+	Router   Router
+	Cache    CacheStore
+	PDF      PDFConverter
+	Renderer Renderer
+
+	StatsInterval   time.Duration
+	ShutdownTimeout time.Duration
+
+	AccessLog   bool
+	LogSample   int
+	SlowRequest time.Duration
+	LogRules    []LogRule
+
+	AccessLogFile   string
+	ErrorLogFile    string
+	LogRotation     Rotation
+	AccessLogWriter io.Writer
+	ErrorLogWriter  io.Writer
 
 	cache map[string]interface{}
 }
@@ -33,9 +134,49 @@ func (c *Config) frontend() string {
 }
 
 func (c *Config) backend() string {
+	if c.Backend == "" && len(c.Backends) > 0 {
+		return c.addr(c.Backends[0], "")
+	}
+
 	return c.addr(c.Backend, "")
 }
 
+func (c *Config) backends() []string {
+	if len(c.Backends) == 0 && c.backend() == "" {
+		return nil
+	}
+
+	if len(c.Backends) == 0 {
+		return []string{c.backend()}
+	}
+
+	bases := []string{}
+
+	for _, b := range c.Backends {
+		if base := c.addr(b, ""); base != "" {
+			bases = append(bases, base)
+		}
+	}
+
+	return bases
+}
+
+func (c *Config) graphql() string {
+	if c.GraphQL == "" {
+		return "graphql"
+	}
+
+	return strings.TrimPrefix(c.GraphQL, "/")
+}
+
+func (c *Config) refresh() time.Duration {
+	if c.Refresh <= 0 {
+		return 30 * time.Second
+	}
+
+	return c.Refresh
+}
+
 func (c *Config) frontendExt() string {
 	return ".html"
 }
@@ -52,15 +193,43 @@ func (c *Config) backendPath() string {
 	return c.path(c.backend())
 }
 
-func (c *Config) layout() string {
-	if c.Layout == "" {
-		return ""
+func (c *Config) notFound() string {
+	if c.NotFound == "" {
+		return "404" + c.frontendExt()
+	}
+
+	return strings.TrimPrefix(c.NotFound, "/")
+}
+
+// rawErrors marks upstream errors that pass through undecorated.
+const rawErrors = "raw"
+
+func (c *Config) errorTemplate(status int) (string, bool) {
+	if status < 400 || len(c.Errors) == 0 {
+		return "", false
+	}
+
+	code := strconv.Itoa(status)
+
+	for _, key := range []string{code, code[:1] + "xx"} {
+		if tmpl, ok := c.Errors[key]; ok {
+			return strings.TrimPrefix(tmpl, "/"), true
+		}
+	}
+
+	return "", false
+}
+
+func (c *Config) unwrap() []string {
+	if c.Unwrap == "" {
+		return nil
 	}
 
-	d := strings.TrimPrefix(path.Dir(c.Layout), "/")
-	n := files.Name(c.Layout)
+	return strings.Split(strings.Trim(c.Unwrap, "."), ".")
+}
 
-	return path.Join(d, n)
+func (c *Config) layout() string {
+	return layoutName(c.Layout)
 }
 
 func (c *Config) path(raw string) string {
@@ -87,6 +256,10 @@ func (c *Config) prod() bool {
 	return c.Prod
 }
 
+func (c *Config) noindex() bool {
+	return c.Staging || !c.Prod
+}
+
 func (c *Config) proxy() bool {
 	return c.Proxy
 }
@@ -95,6 +268,76 @@ func (c *Config) debug() bool {
 	return c.Debug
 }
 
+func (c *Config) authRealm() string {
+	if c.AuthRealm == "" {
+		return "Restricted"
+	}
+
+	return c.AuthRealm
+}
+
+func (c *Config) authLimit() int {
+	if c.AuthLimit <= 0 {
+		return 10
+	}
+
+	return c.AuthLimit
+}
+
+// authProxy lists the proxies trusted to forward client addresses for
+// auth lockouts, defaulting to ones on the same host behind a Proxy.
+func (c *Config) authProxy() []string {
+	if !c.Proxy {
+		return nil
+	}
+
+	if len(c.AuthProxy) == 0 {
+		return []string{"127.0.0.0/8", "::1"}
+	}
+
+	return c.AuthProxy
+}
+
+func (c *Config) bufferLimit() int64 {
+	if c.BufferLimit <= 0 {
+		return 1 << 20
+	}
+
+	return c.BufferLimit
+}
+
+func (c *Config) shutdownTimeout() time.Duration {
+	if c.ShutdownTimeout <= 0 {
+		return 30 * time.Second
+	}
+
+	return c.ShutdownTimeout
+}
+
+func (c *Config) themeCookie() string {
+	if c.ThemeCookie == "" {
+		return "theme"
+	}
+
+	return c.ThemeCookie
+}
+
+func (c *Config) icon() string {
+	if c.Icon == "" {
+		return "icon.png"
+	}
+
+	return strings.TrimPrefix(c.Icon, "/")
+}
+
+func (c *Config) iconDir() string {
+	if c.IconDir == "" {
+		return "icons"
+	}
+
+	return strings.Trim(c.IconDir, "/")
+}
+
 func (c *Config) dir() string {
 	if c.Dir == "" {
 		return "."
@@ -166,17 +409,28 @@ func (c *Config) json() map[string]interface{} {
 }
 
 func (c *Config) load(rel string) map[string]interface{} {
-	cfg := Env{}
+	cfg, err := c.loadE(rel)
 
-	if !files.HasFile(rel) {
-		log.Fatalf("unknown file: %s\n", rel)
+	if err != nil {
+		log.Fatalln(err)
 		return nil
 	}
 
-	if err := json.Unmarshal(files.Read(rel), &cfg); err != nil {
-		log.Fatalf("parse error: %s\n", err)
-		return nil
+	return cfg
+}
+
+func (c *Config) loadE(rel string) (map[string]interface{}, error) {
+	cfg := Env{}
+
+	data, err := files.ReadE(rel)
+
+	if err != nil {
+		return nil, fmt.Errorf("unknown file: %s", rel)
 	}
 
-	return cfg
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse error: %s (%s)", rel, err)
+	}
+
+	return cfg, nil
 }
@@ -0,0 +1,188 @@
+package web
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// ErrInvalidCookie is returned for cookies that fail verification.
+var ErrInvalidCookie = errors.New("invalid cookie")
+
+type cookies struct {
+	prod bool
+	sign []byte
+	seal cipher.AEAD
+}
+
+func (w *Web) newCookies() *cookies {
+	secret := []byte(w.config.Secret)
+
+	if len(secret) == 0 {
+		if w.config.prod() {
+			log.Println("cookies: no Secret set, so signed cookies and flash messages break on restart and across instances")
+		}
+
+		secret = make([]byte, 32)
+
+		if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	block, err := aes.NewCipher(deriveKey(secret, "encrypt"))
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	seal, err := cipher.NewGCM(block)
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	return &cookies{
+		prod: w.config.prod(),
+		sign: deriveKey(secret, "sign"),
+		seal: seal,
+	}
+}
+
+// SetCookie sets a plain cookie, with secure defaults for unset fields.
+func (w *Web) SetCookie(rw http.ResponseWriter, c *http.Cookie) {
+	w.cookies.set(rw, c, c.Value)
+}
+
+// SetSignedCookie sets a cookie whose value is signed with the secret.
+func (w *Web) SetSignedCookie(rw http.ResponseWriter, c *http.Cookie) {
+	w.cookies.set(rw, c, w.cookies.signed(c.Name, c.Value))
+}
+
+// SignedCookie returns the verified value of a signed cookie.
+func (w *Web) SignedCookie(r *http.Request, name string) (string, error) {
+	c, err := r.Cookie(name)
+
+	if err != nil {
+		return "", err
+	}
+
+	return w.cookies.unsigned(name, c.Value)
+}
+
+// SetEncryptedCookie sets a cookie whose value is encrypted with the secret.
+func (w *Web) SetEncryptedCookie(rw http.ResponseWriter, c *http.Cookie) {
+	val, err := w.cookies.encrypted(c.Name, c.Value)
+
+	if err != nil {
+		log.Println(c.Name, err)
+		return
+	}
+
+	w.cookies.set(rw, c, val)
+}
+
+// EncryptedCookie returns the decrypted value of an encrypted cookie.
+func (w *Web) EncryptedCookie(r *http.Request, name string) (string, error) {
+	c, err := r.Cookie(name)
+
+	if err != nil {
+		return "", err
+	}
+
+	return w.cookies.decrypted(name, c.Value)
+}
+
+func (cs *cookies) set(rw http.ResponseWriter, c *http.Cookie, value string) {
+	out := *c
+	out.Value = value
+
+	if out.Path == "" {
+		out.Path = "/"
+	}
+
+	if out.SameSite == 0 || out.SameSite == http.SameSiteDefaultMode {
+		out.SameSite = http.SameSiteLaxMode
+	}
+
+	if cs.prod {
+		out.Secure = true
+	}
+
+	http.SetCookie(rw, &out)
+}
+
+func (cs *cookies) signed(name, value string) string {
+	val := base64.RawURLEncoding.EncodeToString([]byte(value))
+	return val + "." + cs.mac(name, val)
+}
+
+func (cs *cookies) unsigned(name, value string) (string, error) {
+	dot := strings.LastIndex(value, ".")
+
+	if dot == -1 {
+		return "", ErrInvalidCookie
+	}
+
+	val, sig := value[:dot], value[dot+1:]
+
+	if !hmac.Equal([]byte(sig), []byte(cs.mac(name, val))) {
+		return "", ErrInvalidCookie
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(val)
+
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+
+	return string(raw), nil
+}
+
+func (cs *cookies) encrypted(name, value string) (string, error) {
+	nonce := make([]byte, cs.seal.NonceSize())
+
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	out := cs.seal.Seal(nonce, nonce, []byte(value), []byte(name))
+	return base64.RawURLEncoding.EncodeToString(out), nil
+}
+
+func (cs *cookies) decrypted(name, value string) (string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+
+	if err != nil || len(raw) < cs.seal.NonceSize() {
+		return "", ErrInvalidCookie
+	}
+
+	size := cs.seal.NonceSize()
+	out, err := cs.seal.Open(nil, raw[:size], raw[size:], []byte(name))
+
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+
+	return string(out), nil
+}
+
+func (cs *cookies) mac(name, value string) string {
+	m := hmac.New(sha256.New, cs.sign)
+	m.Write([]byte(name + "=" + value))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+func deriveKey(secret []byte, purpose string) []byte {
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(purpose))
+	return m.Sum(nil)
+}
@@ -0,0 +1,84 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/sats-group/abc/internal/files"
+)
+
+// darkSuffix marks a stylesheet as the dark variant of its sibling, so
+// main-dark.css pairs with main.css.
+const darkSuffix = "-dark"
+
+var darkCSS = &assetType{
+	name: "css",
+	ext:  ".css",
+	html: "<link rel=\"stylesheet\" href=\"%s\" media=\"(prefers-color-scheme: dark)\">%s",
+}
+
+type requestKey struct{}
+
+func withRequest(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), requestKey{}, r))
+}
+
+// splitDark separates dark stylesheet variants from the stylesheets
+// they pair with. Dark files without a light sibling stay as they are.
+func (a *assets) splitDark(rels []string) ([]string, []string) {
+	listed := map[string]bool{}
+
+	for _, rel := range rels {
+		listed[rel] = true
+	}
+
+	light, dark := []string{}, []string{}
+
+	for _, rel := range rels {
+		if base, ok := a.lightSibling(rel); ok && (listed[base] || files.HasFile(filepath.Join(a.dir, base))) {
+			dark = append(dark, rel)
+			continue
+		}
+
+		light = append(light, rel)
+	}
+
+	return light, dark
+}
+
+func (a *assets) lightSibling(rel string) (string, bool) {
+	ext := filepath.Ext(rel)
+	base := strings.TrimSuffix(rel, ext)
+
+	if ext != css.ext || !strings.HasSuffix(base, darkSuffix) {
+		return "", false
+	}
+
+	return strings.TrimSuffix(base, darkSuffix) + ext, true
+}
+
+// themeFunc creates the {{theme}} func, returning the theme chosen in
+// the theme cookie ("light" or "dark"), or nothing to follow the system.
+func (e *engine) themeFunc(ctx context.Context) func() string {
+	return func() string {
+		r, ok := ctx.Value(requestKey{}).(*http.Request)
+
+		if !ok {
+			return ""
+		}
+
+		c, err := r.Cookie(e.config.themeCookie())
+
+		if err != nil || (c.Value != "light" && c.Value != "dark") {
+			return ""
+		}
+
+		return c.Value
+	}
+}
+
+func themePlaceholder() string {
+	return ""
+}
@@ -0,0 +1,53 @@
+package web
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// staticDenied reports whether the static handler must not serve a path
+// raw: template sources it would otherwise expose, like backend
+// templates, partials and the layout, JSON data files and anything
+// matching StaticDeny. Paths are cleaned first, like http.Dir does.
+func (c *Config) staticDenied(p string) bool {
+	p = path.Clean("/" + p)
+
+	if path.Ext(p) == c.backendExt() || isPartial(p) {
+		return true
+	}
+
+	for _, pattern := range c.StaticDeny {
+		if matchPath(pattern, p) {
+			return true
+		}
+	}
+
+	for _, file := range c.staticSources() {
+		if p == file {
+			return true
+		}
+	}
+
+	return false
+}
+
+// staticSources lists the site paths of the layout and the JSON data
+// files inside the site dir.
+func (c *Config) staticSources() []string {
+	sources := []string{}
+
+	if layout := strings.TrimPrefix(c.Layout, "/"); layout != "" && path.Ext(layout) == "" {
+		sources = append(sources, "/"+layout+c.frontendExt())
+	} else if layout != "" {
+		sources = append(sources, "/"+layout)
+	}
+
+	for _, rel := range c.JSON {
+		if p, err := filepath.Rel(c.dir(), rel); err == nil && !strings.HasPrefix(p, "..") {
+			sources = append(sources, "/"+filepath.ToSlash(p))
+		}
+	}
+
+	return sources
+}
@@ -0,0 +1,41 @@
+package web
+
+import "testing"
+
+func TestStaticDenied(t *testing.T) {
+	c := &Config{
+		Dir:        "site",
+		Layout:     "layout",
+		JSON:       []string{"site/data/posts.json", "other/config.json"},
+		StaticDeny: []string{"/private/**", "/*.bak"},
+	}
+
+	denied := []string{
+		"/page.tmpl",
+		"/partials/nav.html",
+		"/_partials/nav.html",
+		"/layout.html",
+		"/data/posts.json",
+		"/private",
+		"/private/key.pem",
+		"/notes.bak",
+		// Paths are cleaned like http.Dir does before checking.
+		"/css/../partials/nav.html",
+		"partials/nav.html",
+		"//layout.html",
+	}
+
+	for _, p := range denied {
+		if !c.staticDenied(p) {
+			t.Errorf("%s served", p)
+		}
+	}
+
+	served := []string{"/index.html", "/css/site.css", "/data/other.json", "/config.json", "/docs/notes.bak", "/privately.html"}
+
+	for _, p := range served {
+		if c.staticDenied(p) {
+			t.Errorf("%s denied", p)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package web
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/codegangsta/negroni"
+	"github.com/sats-group/abc/internal/files"
+)
+
+// newDevServer proxies asset requests in dev to a frontend dev server,
+// like Vite or webpack. Websocket upgrades for hot reloading and requests
+// under DevServerPaths always go there, as do requests for files with an
+// extension that the site doesn't have. Templates are still rendered.
+func (w *Web) newDevServer() Middleware {
+	if w.config.DevServer == "" || w.config.prod() {
+		return nil
+	}
+
+	target, err := url.Parse(w.config.DevServer)
+
+	if err != nil || target.Host == "" {
+		log.Fatalf("invalid dev server: %s\n", w.config.DevServer)
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	prefixes := w.config.devServerPaths()
+
+	fn := func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		if isUpgrade(r) || w.devServed(r.URL.Path, prefixes) {
+			proxy.ServeHTTP(rw, r)
+			return
+		}
+
+		next(rw, r)
+	}
+
+	return negroni.HandlerFunc(fn)
+}
+
+func (w *Web) devServed(p string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+
+	ext := path.Ext(p)
+
+	if ext == "" || ext == w.config.frontendExt() || ext == w.config.backendExt() {
+		return false
+	}
+
+	return !files.HasFile(filepath.Join(w.config.dir(), filepath.FromSlash(p)))
+}
+
+func (c *Config) devServerPaths() []string {
+	if c.DevServerPaths == nil {
+		return []string{"/@", "/node_modules/", "/__webpack_hmr"}
+	}
+
+	return c.DevServerPaths
+}
+
+func isUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
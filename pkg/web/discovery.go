@@ -0,0 +1,271 @@
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Resolver lists the base URLs of the backend instances currently
+// available, replacing a fixed Backend.
+type Resolver interface {
+	Resolve(ctx context.Context) ([]string, error)
+}
+
+// SRVResolver finds backend instances with a DNS SRV lookup.
+type SRVResolver struct {
+	Service string
+	Proto   string
+	Name    string
+	Scheme  string
+}
+
+// ConsulResolver finds healthy backend instances in a Consul catalog.
+type ConsulResolver struct {
+	Address string
+	Service string
+	Scheme  string
+}
+
+// KubernetesResolver finds ready backend pods from a service's
+// endpoints, using the in-cluster service account. Namespace defaults
+// to the pod's own.
+type KubernetesResolver struct {
+	Namespace string
+	Service   string
+	Port      string
+	Scheme    string
+
+	mu     sync.Mutex
+	client *http.Client
+}
+
+const (
+	kubeAPI   = "https://kubernetes.default.svc"
+	kubeToken = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	kubeCA    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	kubeNS    = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// discover resolves the backend instances once, returning the job
+// refreshing them every interval.
+func (p *proxy) discover(res Resolver, every time.Duration) func(context.Context) error {
+	up := p.upstream("/")
+
+	resolve := func(ctx context.Context) error {
+		if up == nil {
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, every)
+		defer cancel()
+
+		bases, err := res.Resolve(ctx)
+
+		if err != nil {
+			return fmt.Errorf("resolve: %s", err)
+		}
+
+		up.pool.update(bases)
+
+		return nil
+	}
+
+	if err := resolve(context.Background()); err != nil {
+		log.Println(err)
+	}
+
+	return resolve
+}
+
+// Resolve implements Resolver.
+func (s *SRVResolver) Resolve(ctx context.Context) ([]string, error) {
+	_, addrs, err := net.DefaultResolver.LookupSRV(ctx, s.Service, s.Proto, s.Name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	bases := []string{}
+
+	for _, addr := range addrs {
+		host := net.JoinHostPort(addr.Target, strconv.Itoa(int(addr.Port)))
+		bases = append(bases, baseURL(s.Scheme, host))
+	}
+
+	return bases, nil
+}
+
+// Resolve implements Resolver.
+func (c *ConsulResolver) Resolve(ctx context.Context) ([]string, error) {
+	addr := c.Address
+
+	if addr == "" {
+		addr = "http://127.0.0.1:8500"
+	}
+
+	u := fmt.Sprintf("%s/v1/health/service/%s?passing=true", addr, url.PathEscape(c.Service))
+	entries := []struct {
+		Node    struct{ Address string }
+		Service struct {
+			Address string
+			Port    int
+		}
+	}{}
+
+	if err := getJSON(ctx, http.DefaultClient, u, "", &entries); err != nil {
+		return nil, err
+	}
+
+	bases := []string{}
+
+	for _, e := range entries {
+		host := e.Service.Address
+
+		if host == "" {
+			host = e.Node.Address
+		}
+
+		bases = append(bases, baseURL(c.Scheme, net.JoinHostPort(host, strconv.Itoa(e.Service.Port))))
+	}
+
+	return bases, nil
+}
+
+// Resolve implements Resolver.
+func (k *KubernetesResolver) Resolve(ctx context.Context) ([]string, error) {
+	// The token is read on every call as projected tokens are rotated.
+	token, err := ioutil.ReadFile(kubeToken)
+
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := k.apiClient()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ns, err := k.namespace()
+
+	if err != nil {
+		return nil, err
+	}
+
+	u := fmt.Sprintf("%s/api/v1/namespaces/%s/endpoints/%s", kubeAPI, url.PathEscape(ns), url.PathEscape(k.Service))
+	endpoints := struct {
+		Subsets []struct {
+			Addresses []struct{ IP string }
+			Ports     []struct {
+				Name string
+				Port int
+			}
+		}
+	}{}
+
+	if err := getJSON(ctx, client, u, string(token), &endpoints); err != nil {
+		return nil, err
+	}
+
+	bases := []string{}
+
+	for _, set := range endpoints.Subsets {
+		port := 0
+
+		for _, p := range set.Ports {
+			if port == 0 || p.Name == k.Port || strconv.Itoa(p.Port) == k.Port {
+				port = p.Port
+			}
+		}
+
+		for _, addr := range set.Addresses {
+			bases = append(bases, baseURL(k.Scheme, net.JoinHostPort(addr.IP, strconv.Itoa(port))))
+		}
+	}
+
+	return bases, nil
+}
+
+// apiClient trusts the cluster CA, created once and reused so refreshes
+// share connections.
+func (k *KubernetesResolver) apiClient() (*http.Client, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.client != nil {
+		return k.client, nil
+	}
+
+	ca, err := ioutil.ReadFile(kubeCA)
+
+	if err != nil {
+		return nil, err
+	}
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca)
+
+	k.client = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+
+	return k.client, nil
+}
+
+func (k *KubernetesResolver) namespace() (string, error) {
+	if k.Namespace != "" {
+		return k.Namespace, nil
+	}
+
+	ns, err := ioutil.ReadFile(kubeNS)
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(ns)), nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, u string, token string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+
+	if err != nil {
+		return err
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d: %s", res.StatusCode, u)
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func baseURL(scheme string, host string) string {
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	return scheme + "://" + host + "/"
+}
@@ -0,0 +1,179 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sats-group/abc/internal/files"
+)
+
+// A diskCache keeps built bundles in a directory per asset version, so
+// restarts and other processes of the same build reuse them. Entries are
+// checked against their checksums before use.
+type diskCache struct {
+	root     string
+	dir      string
+	readOnly bool
+}
+
+type diskEntry struct {
+	Mime  string            `json:"mime"`
+	Paths []string          `json:"paths"`
+	Sums  map[string]string `json:"sums"`
+}
+
+var diskVariants = map[string]func(*assetCache) *[]byte{
+	"":    func(f *assetCache) *[]byte { return &f.bytes },
+	".gz": func(f *assetCache) *[]byte { return &f.gzip },
+	".br": func(f *assetCache) *[]byte { return &f.brotli },
+}
+
+func (w *Web) newDiskCache(version string) *diskCache {
+	if w.config.AssetCache == "" || !w.config.prod() {
+		return nil
+	}
+
+	d := &diskCache{root: w.config.AssetCache, dir: filepath.Join(w.config.AssetCache, version)}
+
+	if err := files.MkdirAll(d.dir); err != nil {
+		log.Fatalln(err)
+	}
+
+	now := time.Now()
+
+	// Prebuilt bundles on a read-only filesystem are only loaded.
+	if err := os.Chtimes(d.dir, now, now); err != nil {
+		d.readOnly = true
+		return d
+	}
+
+	d.cleanup(w.config.assetCacheTTL())
+
+	return d
+}
+
+func (c *Config) assetCacheTTL() time.Duration {
+	if c.AssetCacheTTL <= 0 {
+		return 7 * 24 * time.Hour
+	}
+
+	return c.AssetCacheTTL
+}
+
+// cleanup removes the bundles of other versions unused for longer than
+// ttl, keeping those of instances still running mid-deploy.
+func (d *diskCache) cleanup(ttl time.Duration) {
+	infos, err := ioutil.ReadDir(d.root)
+
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, info := range infos {
+		dir := filepath.Join(d.root, info.Name())
+
+		if !info.IsDir() || dir == d.dir || time.Since(info.ModTime()) < ttl {
+			continue
+		}
+
+		if err := os.RemoveAll(dir); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func (d *diskCache) load(version string, name string) (*assetCache, bool) {
+	dir := filepath.Join(d.root, version)
+	meta, err := files.ReadE(filepath.Join(dir, name+".json"))
+
+	if err != nil {
+		return nil, false
+	}
+
+	entry := &diskEntry{}
+
+	if err := json.Unmarshal(meta, entry); err != nil {
+		d.remove(dir, name)
+		return nil, false
+	}
+
+	file := &assetCache{name: name, mime: entry.Mime, paths: entry.Paths, time: time.Now()}
+
+	for suffix, field := range diskVariants {
+		sum, ok := entry.Sums[suffix]
+
+		if !ok {
+			continue
+		}
+
+		b, err := files.ReadE(filepath.Join(dir, name+suffix))
+
+		if err != nil || checksum(b) != sum {
+			log.Println("asset cache: invalid entry:", name+suffix)
+			d.remove(dir, name)
+			return nil, false
+		}
+
+		*field(file) = b
+	}
+
+	return file, true
+}
+
+// store writes a bundle and its compressed variants, then the entry
+// describing them, so partial entries are never loaded.
+func (d *diskCache) store(file *assetCache) {
+	if d.readOnly {
+		return
+	}
+
+	entry := &diskEntry{Mime: file.mime, Paths: file.paths, Sums: map[string]string{}}
+
+	for suffix, field := range diskVariants {
+		b := *field(file)
+
+		if b == nil {
+			continue
+		}
+
+		if err := files.WriteAtomic(filepath.Join(d.dir, file.name+suffix), bytes.NewReader(b), files.WriteOptions{}); err != nil {
+			log.Println("asset cache:", err)
+			return
+		}
+
+		entry.Sums[suffix] = checksum(b)
+	}
+
+	meta, err := json.Marshal(entry)
+
+	if err == nil {
+		err = files.WriteAtomic(filepath.Join(d.dir, file.name+".json"), bytes.NewReader(meta), files.WriteOptions{})
+	}
+
+	if err != nil {
+		log.Println("asset cache:", err)
+	}
+}
+
+func (d *diskCache) remove(dir string, name string) {
+	if d.readOnly {
+		return
+	}
+
+	for _, suffix := range []string{".json", "", ".gz", ".br"} {
+		_ = os.Remove(filepath.Join(dir, name+suffix))
+	}
+}
+
+func checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,301 @@
+package web
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// An Email holds the HTML and plain text bodies of a rendered message.
+type Email struct {
+	HTML string
+	Text string
+}
+
+var (
+	cssComments = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssRules    = regexp.MustCompile(`(?s)([^{}@]+)\{([^{}]*)\}`)
+	cssSimple   = regexp.MustCompile(`^([a-zA-Z0-9]*)((?:[.#][\w-]+)*)$`)
+	cssParts    = regexp.MustCompile(`[.#][\w-]+`)
+	textSpace   = regexp.MustCompile(`[ \t]+`)
+	textLines   = regexp.MustCompile(`\n{3,}`)
+)
+
+// ExecuteLayout renders a template file with data inside a layout other
+// than the configured one. An empty layout renders the file alone.
+func (w *Web) ExecuteLayout(file string, layout string, input Env) (*bytes.Buffer, error) {
+	env := Env{layoutKey: noLayout}
+
+	for k, v := range input {
+		env[k] = v
+	}
+
+	if layout != "" {
+		env[layoutKey] = layout
+	}
+
+	return w.engine.execute(file, env)
+}
+
+// Email renders a template file with data as an email, with styles from
+// <style> blocks inlined into elements and a plain text alternative.
+func (w *Web) Email(file string, layout string, input Env) (*Email, error) {
+	out, err := w.ExecuteLayout(file, layout, input)
+
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := html.Parse(out)
+
+	if err != nil {
+		return nil, err
+	}
+
+	inlineCSS(doc)
+
+	buf := new(bytes.Buffer)
+
+	if err := html.Render(buf, doc); err != nil {
+		return nil, err
+	}
+
+	return &Email{HTML: buf.String(), Text: htmlText(doc)}, nil
+}
+
+type cssRule struct {
+	tag     string
+	classes []string
+	id      string
+	decls   string
+	weight  int
+}
+
+// inlineCSS moves simple rules (tag, .class, #id and combinations) from
+// <style> blocks into style attributes, ordered by specificity. Other
+// rules, like media queries, stay in place for clients that support them.
+func inlineCSS(doc *html.Node) {
+	rules := []cssRule{}
+
+	walkHTML(doc, func(n *html.Node) {
+		if n.DataAtom != atom.Style || n.FirstChild == nil {
+			return
+		}
+
+		css, at := splitAtRules(cssComments.ReplaceAllString(n.FirstChild.Data, ""))
+		kept := css
+
+		for _, m := range cssRules.FindAllStringSubmatch(css, -1) {
+			parsed, ok := parseSelectors(m[1], strings.TrimSpace(m[2]))
+
+			if !ok {
+				continue
+			}
+
+			rules = append(rules, parsed...)
+			kept = strings.Replace(kept, m[0], "", 1)
+		}
+
+		n.FirstChild.Data = strings.TrimSpace(strings.TrimSpace(kept) + "\n" + at)
+	})
+
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].weight < rules[j].weight })
+
+	walkHTML(doc, func(n *html.Node) {
+		if n.Type != html.ElementNode {
+			return
+		}
+
+		decls := []string{}
+
+		for _, rule := range rules {
+			if rule.matches(n) {
+				decls = append(decls, strings.TrimSuffix(rule.decls, ";"))
+			}
+		}
+
+		if len(decls) == 0 {
+			return
+		}
+
+		if style := attr(n, "style"); style != "" {
+			decls = append(decls, strings.TrimSuffix(style, ";"))
+		}
+
+		setAttr(n, "style", strings.Join(decls, ";"))
+	})
+}
+
+// splitAtRules separates at-rules, like media queries, from plain rules.
+func splitAtRules(css string) (string, string) {
+	plain, at := new(strings.Builder), new(strings.Builder)
+
+	for i := 0; i < len(css); i++ {
+		if css[i] != '@' {
+			plain.WriteByte(css[i])
+			continue
+		}
+
+		depth, j := 0, i
+
+		for ; j < len(css); j++ {
+			if css[j] == ';' && depth == 0 {
+				break
+			}
+
+			if css[j] == '{' {
+				depth++
+			}
+
+			if css[j] == '}' {
+				if depth--; depth == 0 {
+					break
+				}
+			}
+		}
+
+		if j == len(css) {
+			j--
+		}
+
+		at.WriteString(css[i:j+1] + "\n")
+		i = j
+	}
+
+	return plain.String(), strings.TrimSpace(at.String())
+}
+
+func parseSelectors(selectors string, decls string) ([]cssRule, bool) {
+	rules := []cssRule{}
+
+	for _, sel := range strings.Split(selectors, ",") {
+		m := cssSimple.FindStringSubmatch(strings.TrimSpace(sel))
+
+		if m == nil || (m[1] == "" && m[2] == "") {
+			return nil, false
+		}
+
+		rule := cssRule{tag: strings.ToLower(m[1]), decls: decls}
+
+		if rule.tag != "" {
+			rule.weight = 1
+		}
+
+		for _, part := range cssParts.FindAllString(m[2], -1) {
+			if part[0] == '#' {
+				rule.id = part[1:]
+				rule.weight += 100
+			} else {
+				rule.classes = append(rule.classes, part[1:])
+				rule.weight += 10
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, true
+}
+
+func (rule cssRule) matches(n *html.Node) bool {
+	if rule.tag != "" && rule.tag != n.Data {
+		return false
+	}
+
+	if rule.id != "" && rule.id != attr(n, "id") {
+		return false
+	}
+
+	classes := strings.Fields(attr(n, "class"))
+
+	for _, want := range rule.classes {
+		found := false
+
+		for _, c := range classes {
+			found = found || c == want
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// htmlText renders a document as plain text, keeping paragraphs apart
+// and link targets after their text.
+func htmlText(doc *html.Node) string {
+	buf := new(strings.Builder)
+	var walk func(*html.Node)
+
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			buf.WriteString(textSpace.ReplaceAllString(strings.Replace(n.Data, "\n", " ", -1), " "))
+			return
+		case n.DataAtom == atom.Head || n.DataAtom == atom.Style || n.DataAtom == atom.Script:
+			return
+		case n.DataAtom == atom.Br:
+			buf.WriteString("\n")
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+
+		switch n.DataAtom {
+		case atom.A:
+			if href := attr(n, "href"); href != "" {
+				buf.WriteString(" (" + href + ")")
+			}
+		case atom.P, atom.Div, atom.H1, atom.H2, atom.H3, atom.H4, atom.Tr, atom.Table, atom.Ul, atom.Ol:
+			buf.WriteString("\n\n")
+		case atom.Li:
+			buf.WriteString("\n")
+		}
+	}
+
+	walk(doc)
+
+	lines := strings.Split(buf.String(), "\n")
+
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+
+	return strings.TrimSpace(textLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+func walkHTML(n *html.Node, fn func(*html.Node)) {
+	fn(n)
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walkHTML(c, fn)
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+func setAttr(n *html.Node, key, val string) {
+	for i, a := range n.Attr {
+		if a.Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const acceptEncoding = "Accept-Encoding"
+
+// decodedBody reads a compressed body as plain bytes and closes both.
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	var err error
+
+	for _, c := range b.closers {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+// identity asks the backend for an uncompressed body when configured to,
+// rather than decompressing it here. Removing the header wouldn't do, as
+// the transport then asks for gzip itself. Otherwise forwarded headers
+// only ask for encodings decompress handles.
+func (p *proxy) identity(req *http.Request) {
+	switch {
+	case p.config.StripAcceptEncoding:
+		req.Header.Set(acceptEncoding, "identity")
+	case req.Header.Get(acceptEncoding) != "":
+		req.Header.Set(acceptEncoding, "gzip, deflate")
+	}
+}
+
+// bodyless reports if a response has no body to decode or decorate.
+func bodyless(r *http.Request, res *http.Response) bool {
+	return r.Method == http.MethodHead || res.StatusCode == http.StatusNoContent || res.StatusCode == http.StatusNotModified || res.Body == http.NoBody
+}
+
+// decompress replaces a gzip or deflate encoded body with its decoded
+// content, since decoration needs the plain JSON.
+func decompress(res *http.Response) error {
+	encoding := strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding")))
+	var r io.Reader
+	var closer io.Closer
+
+	switch encoding {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(res.Body)
+
+		if err != nil {
+			return err
+		}
+
+		r, closer = zr, zr
+	case "deflate":
+		// Deflate is meant to be zlib wrapped, but some servers send it raw.
+		br := bufio.NewReader(res.Body)
+		head, err := br.Peek(2)
+
+		if err == nil && (uint16(head[0])<<8|uint16(head[1]))%31 == 0 && head[0]&0x0f == 8 {
+			zr, err := zlib.NewReader(br)
+
+			if err != nil {
+				return err
+			}
+
+			r, closer = zr, zr
+		} else {
+			fr := flate.NewReader(br)
+			r, closer = fr, fr
+		}
+	default:
+		return nil
+	}
+
+	res.Body = &decodedBody{Reader: r, closers: []io.Closer{closer, res.Body}}
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+
+	return nil
+}
@@ -2,14 +2,19 @@ package web
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"html/template"
+	"io"
 	"log"
 	"net/http"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/sats-group/abc/internal/files"
-	"github.com/sats-group/abc/internal/tmpl"
+	"github.com/sats-group/abc/pkg/tmpl"
 )
 
 const (
@@ -18,22 +23,76 @@ const (
 )
 
 type engine struct {
-	config    *Config
-	funcs     template.FuncMap
-	templates *template.Template
+	config  *Config
+	funcs   template.FuncMap
+	store   CacheStore
+	cookies *cookies
+	stats   *counters
+
+	nonceMark string
+	indexMu   sync.Mutex
+
+	mu      sync.RWMutex
+	set     *templateSet
+	audited map[string]bool
+	envs    []func(*http.Request) Env
+	hooks   []func(time.Duration, error)
+
+	overlay   string
+	candidate *engine
+}
+
+// A templateSet keeps parsed templates that are never executed directly,
+// handing out clones so each render can bind its own funcs. Clones get
+// the funcs they were parsed with back before they are reused.
+type templateSet struct {
+	root   *template.Template
+	funcs  template.FuncMap
+	clones sync.Pool
+	ext    Templates
+	stamp  string
 }
 
 func (w *Web) newEngine() *engine {
-	return &engine{
-		config: w.config,
-		funcs:  tmpl.Funcs,
+	funcs := template.FuncMap{
+		"cache":   cachePlaceholder,
+		"lines":   linesPlaceholder,
+		"meta":    metaFunc,
+		"nonce":   noncePlaceholder,
+		"partial": partialPlaceholder,
+		"theme":   themePlaceholder,
+	}
+
+	for k, v := range tmpl.Funcs {
+		funcs[k] = v
+	}
+
+	if w.config.Strict && w.config.prod() {
+		funcs["noescape"] = escapeFunc
+	}
+
+	e := &engine{
+		config:  w.config,
+		funcs:   funcs,
+		store:   w.store,
+		cookies: w.cookies,
+		stats:   w.stats,
+		audited: map[string]bool{},
+
+		nonceMark: w.cookies.nonceMark(),
+	}
+
+	if w.config.Candidate != "" {
+		e.candidate = e.newCandidate()
 	}
+
+	return e
 }
 
 func (e *engine) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	path := e.expandPath(r.URL.Path)
 
-	if r.Method != http.MethodGet || e.skipPath(path) {
+	if r.Method != http.MethodGet || isPartial(path) || e.skipPath(path) {
 		next(rw, r)
 		return
 	}
@@ -42,17 +101,56 @@ func (e *engine) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.Ha
 }
 
 func (e *engine) respond(rw http.ResponseWriter, r *http.Request, status int, file string, data Env) {
+	e.respondFuncs(rw, r, status, file, data, nil, e.config.Stream)
+}
+
+func (e *engine) respondFuncs(rw http.ResponseWriter, r *http.Request, status int, file string, data Env, funcs template.FuncMap, stream bool) {
+	e = e.pick(r.Context())
 	env := e.createEnv(rw, r, data)
-	out, err := e.execute(file, env)
+	file = e.ampVariants(r, file, env)
+	traceTemplate(r.Context(), file, e.layout(env))
+	ctx, cancel := withTimeout(r.Context(), e.config.timeouts(r.URL.Path).render)
+	defer cancel()
+
+	r = withRequest(r.WithContext(withVariant(ctx, e.config.variant(r))))
+
+	defer trackRender(r.Context(), time.Now())
+
+	if stream {
+		if f, ok := rw.(http.Flusher); ok {
+			e.stream(rw, f, r, status, file, env, funcs)
+			return
+		}
+	}
+
+	out, err := e.executeContext(r.Context(), file, env, funcs)
+
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		log.Println(file, ctx.Err())
+		uncache(rw)
+		http503(rw, r)
+		return
+	}
+
+	if err != nil && r.Context().Err() != nil {
+		return
+	}
 
 	if err != nil {
 		log.Println(file, err)
+		uncache(rw)
 		http404(rw, r)
 		return
 	}
 
 	rw.Header().Set(contentTypeKey, contentTypeVal)
-	rw.WriteHeader(http.StatusOK)
+
+	if etag(rw, r, status, out) {
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	rw.WriteHeader(status)
 
 	if _, err = out.WriteTo(rw); err != nil {
 		log.Println(file, err)
@@ -61,33 +159,196 @@ func (e *engine) respond(rw http.ResponseWriter, r *http.Request, status int, fi
 }
 
 func (e *engine) execute(file string, env interface{}) (*bytes.Buffer, error) {
-	if e.templates == nil || !e.config.prod() {
-		if err := e.compileTemplates(); err != nil {
-			return nil, err
+	return e.executeFuncs(file, env, nil)
+}
+
+func (e *engine) executeFuncs(file string, env interface{}, funcs template.FuncMap) (*bytes.Buffer, error) {
+	return e.executeContext(context.Background(), file, env, funcs)
+}
+
+func (e *engine) executeContext(ctx context.Context, file string, env interface{}, funcs template.FuncMap) (*bytes.Buffer, error) {
+	e = e.pick(ctx)
+	buf := new(bytes.Buffer)
+	return buf, e.executeTo(ctx, buf, file, env, funcs)
+}
+
+func (e *engine) executeTo(ctx context.Context, w io.Writer, file string, env interface{}, funcs template.FuncMap) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if _, ok := w.(*flushWriter); !ok {
+		w = &ctxWriter{ctx: ctx, w: w}
+	}
+
+	set, err := e.templateSet()
+
+	if err != nil {
+		return err
+	}
+
+	if set.ext != nil {
+		return e.executeRenderer(ctx, w, set.ext, file, env, funcs)
+	}
+
+	t, err := set.get()
+
+	if err != nil {
+		return err
+	}
+
+	scoped := template.FuncMap{
+		"cache":   e.cacheFunc(ctx, htmlTemplates{t}, nil),
+		"context": func() context.Context { return ctx },
+		"flush":   flushFunc(w),
+		"partial": e.partialFunc(ctx, htmlTemplates{t}, nil),
+		"theme":   e.themeFunc(ctx),
+		"yield":   tmpl.Yield,
+	}
+
+	e.nonceFuncs(ctx, scoped)
+
+	for k, v := range funcs {
+		scoped[k] = v
+	}
+
+	name := e.templateName(file)
+
+	if layout := e.layout(env); layout != "" {
+		scoped["yield"] = e.yield(ctx, t, w, name, env)
+		name = layout
+	}
+
+	t.Funcs(scoped)
+	defer set.put(t, scoped)
+
+	return t.ExecuteTemplate(w, name, env)
+}
+
+func (e *engine) yield(ctx context.Context, t *template.Template, w io.Writer, name string, env interface{}) func() template.HTML {
+	if fw, ok := w.(*flushWriter); ok {
+		return func() template.HTML {
+			if err := t.ExecuteTemplate(fw, name, env); err != nil {
+				log.Println(name, err)
+			}
+
+			return template.HTML("")
 		}
 	}
 
-	if e.config.layout() != "" {
-		e.funcMapLayout(e.templateName(file), env)
-		file = e.config.layout()
+	return func() template.HTML {
+		buf := new(bytes.Buffer)
+
+		if err := t.ExecuteTemplate(&ctxWriter{ctx: ctx, w: buf}, name, env); err != nil {
+			return template.HTML("")
+		}
+
+		return template.HTML(buf.String())
 	}
+}
 
-	return e.template(file, env)
+func (e *engine) hasTemplate(file string) bool {
+	return e.hasName(e.templateName(file))
 }
 
-func (e *engine) template(file string, env interface{}) (*bytes.Buffer, error) {
-	tpl := e.templateName(file)
-	buf := new(bytes.Buffer)
+func (e *engine) hasName(name string) bool {
+	set, err := e.templateSet()
 
-	return buf, e.templates.ExecuteTemplate(buf, tpl, env)
+	return err == nil && set.lookup(name)
 }
 
 func (e *engine) funcMap(funcs template.FuncMap) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	for k, v := range funcs {
+		if k == "noescape" && e.config.Strict && e.config.prod() {
+			continue
+		}
+
 		e.funcs[k] = v
 	}
 
-	e.templates = nil
+	e.set = nil
+
+	if e.candidate != nil {
+		e.candidate.funcMap(funcs)
+	}
+}
+
+func (e *engine) envFunc(fn func(*http.Request) Env) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.envs = append(e.envs, fn)
+
+	if e.candidate != nil {
+		e.candidate.envFunc(fn)
+	}
+}
+
+func (e *engine) compileHook(fn func(time.Duration, error)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.hooks = append(e.hooks, fn)
+
+	if e.candidate != nil {
+		e.candidate.compileHook(fn)
+	}
+}
+
+func (e *engine) templateSet() (*templateSet, error) {
+	e.mu.RLock()
+	set := e.set
+	e.mu.RUnlock()
+
+	if set != nil && e.config.prod() {
+		return set, nil
+	}
+
+	// Dev servers recompile once template files change, not per call.
+	stamp := ""
+
+	if !e.config.prod() {
+		stamp = e.templateStamp()
+
+		if set != nil && stamp != "" && set.stamp == stamp {
+			return set, nil
+		}
+	}
+
+	e.mu.Lock()
+
+	if e.set != nil && e.set != set {
+		set = e.set
+		e.mu.Unlock()
+
+		return set, nil
+	}
+
+	start := time.Now()
+	set, err := e.compileTemplates()
+	took := time.Since(start)
+	hooks := e.hooks
+
+	if err == nil {
+		set.stamp = stamp
+		e.set = set
+	}
+
+	e.mu.Unlock()
+
+	// Hooks run unlocked, so they may render or add hooks themselves.
+	for _, fn := range hooks {
+		fn(took, err)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return set, nil
 }
 
 func (e *engine) createEnv(rw http.ResponseWriter, r *http.Request, data Env) Env {
@@ -96,6 +357,20 @@ func (e *engine) createEnv(rw http.ResponseWriter, r *http.Request, data Env) En
 		"config": e.config.json(),
 	}
 
+	if msg := e.cookies.flash(rw, r); msg != "" {
+		env[flashKey] = msg
+	}
+
+	e.mu.RLock()
+	envs := e.envs
+	e.mu.RUnlock()
+
+	for _, fn := range envs {
+		for key, val := range fn(r) {
+			env[key] = val
+		}
+	}
+
 	for key, val := range data {
 		env[key] = val
 	}
@@ -151,42 +426,148 @@ func (e *engine) skipFile(file string) bool {
 	return false
 }
 
-func (e *engine) compileTemplates() error {
-	e.templates = template.New(e.config.dir())
+// missingTemplate reports if a request has no template to decorate a
+// backend response with, looking it up like renders do: in the template
+// dirs, the site dir and the candidate. Templates failing to compile
+// aren't missing, so the render reports the error.
+func (e *engine) missingTemplate(ctx context.Context, file string) bool {
+	set, err := e.pick(ctx).templateSet()
+
+	return err == nil && !set.lookup(e.templateName(file))
+}
+
+func (e *engine) compileTemplates() (*templateSet, error) {
+	start := time.Now()
+
+	if e.config.Renderer != nil {
+		return e.compileRenderer(start)
+	}
+
+	root := template.New(e.config.dir()).Funcs(e.funcs)
+
+	err := e.walkTemplates(func(dir string, rel string) error {
+		return e.compileTemplate(root, dir, rel)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	e.stats.compiled(len(root.Templates()), time.Since(start))
 
-	return files.Walk(e.config.dir(), func(rel string) error {
-		ext := filepath.Ext(rel)
+	funcs := template.FuncMap{}
 
-		if ext != e.config.frontendExt() && ext != e.config.backendExt() {
-			return nil
+	for k, v := range e.funcs {
+		funcs[k] = v
+	}
+
+	return &templateSet{root: root, funcs: funcs}, nil
+}
+
+// walkTemplates visits the template files of the template dirs, the
+// site dir and any overlay, so later templates replace earlier ones of
+// the same name.
+func (e *engine) walkTemplates(fn func(dir string, rel string) error) error {
+	dirs := append(append([]string{}, e.config.TemplateDirs...), e.config.dir())
+
+	if e.overlay != "" {
+		dirs = append(dirs, e.overlay)
+	}
+
+	for _, dir := range dirs {
+		err := files.Walk(dir, func(rel string) error {
+			if !e.templateFile(rel) {
+				return nil
+			}
+
+			return fn(dir, rel)
+		})
+
+		if err != nil {
+			return err
 		}
+	}
 
-		return e.compileTemplate(rel)
+	return nil
+}
+
+// templateStamp describes the template files by name, size and time,
+// returning "" if they can't be listed.
+func (e *engine) templateStamp() string {
+	b := &strings.Builder{}
+
+	err := e.walkTemplates(func(dir string, rel string) error {
+		info, err := files.Source(filepath.Join(dir, rel))
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(b, "%s %d %d\n", filepath.Join(dir, rel), info.Size(), info.ModTime().UnixNano())
+
+		return nil
 	})
+
+	if err != nil {
+		return ""
+	}
+
+	return b.String()
+}
+
+func (e *engine) templateFile(rel string) bool {
+	ext := filepath.Ext(rel)
+
+	if ext != e.config.frontendExt() && ext != e.config.backendExt() {
+		return false
+	}
+
+	return !files.Excluded(rel)
 }
 
-func (e *engine) compileTemplate(rel string) error {
+func (e *engine) compileTemplate(root *template.Template, dir string, rel string) error {
 	name := e.templateName(rel)
-	data := files.Read(filepath.Join(e.config.dir(), rel))
+	data, err := files.ReadE(filepath.Join(dir, rel))
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = root.New(name).Parse(string(data[:])); err != nil {
+		return err
+	}
 
-	tmpl := e.templates.Funcs(e.funcs).New(name)
-	_, err := tmpl.Parse(string(data[:]))
+	e.audit(root, name, rel)
 
-	return err
+	return nil
 }
 
-func (e *engine) funcMapLayout(name string, env interface{}) {
-	if tmpl := e.templates.Lookup(name); tmpl != nil {
-		tmpl.Funcs(template.FuncMap{
-			"yield": func() template.HTML {
-				buf, err := e.template(name, env)
+func (s *templateSet) lookup(name string) bool {
+	if s.ext != nil {
+		return s.ext.Lookup(name)
+	}
 
-				if err != nil {
-					return template.HTML("")
-				}
+	return s.root.Lookup(name) != nil
+}
 
-				return template.HTML(buf.String())
-			},
-		})
+func (s *templateSet) get() (*template.Template, error) {
+	if t, ok := s.clones.Get().(*template.Template); ok {
+		return t, nil
+	}
+
+	return s.root.Clone()
+}
+
+// put returns a clone to the pool with the funcs it was parsed with.
+// Clones given funcs the set has no key for can't be reset to exactly
+// those, so they are dropped rather than keeping the funcs alive.
+func (s *templateSet) put(t *template.Template, scoped template.FuncMap) {
+	for k := range scoped {
+		if _, ok := s.funcs[k]; !ok {
+			return
+		}
 	}
+
+	t.Funcs(s.funcs)
+	s.clones.Put(t)
 }
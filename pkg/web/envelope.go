@@ -0,0 +1,139 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reserved keys for upstream data in decorated template envs.
+const (
+	metaKey     = "meta"
+	pageKey     = "page"
+	responseKey = "response"
+)
+
+// pageHeaderPrefix marks backend headers carrying page metadata, so
+// X-Page-Title is available as .page.title.
+const pageHeaderPrefix = "X-Page-"
+
+// unwrap replaces an enveloped payload with the value at the configured
+// path, keeping the other top-level envelope fields under "meta".
+func (p *proxy) unwrap(data Env) Env {
+	keys := p.config.unwrap()
+
+	if len(keys) == 0 {
+		return data
+	}
+
+	meta := Env{}
+
+	for key, val := range data {
+		if key != keys[0] {
+			meta[key] = val
+		}
+	}
+
+	var val interface{} = map[string]interface{}(data)
+
+	for _, key := range keys {
+		obj, ok := val.(map[string]interface{})
+
+		if !ok {
+			return data
+		}
+
+		if val, ok = obj[key]; !ok {
+			return data
+		}
+	}
+
+	out, ok := val.(map[string]interface{})
+
+	if !ok {
+		out = Env{keys[len(keys)-1]: val}
+	}
+
+	out[metaKey] = meta
+
+	return out
+}
+
+type upstreamTimeKey struct{}
+
+func withUpstreamTime(r *http.Request, took time.Duration) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), upstreamTimeKey{}, took))
+}
+
+// responseEnv describes a backend response for templates: its status,
+// headers, how long it took and its age in seconds from a cache, so
+// .response.status can pick error states and .response.age staleness.
+func (p *proxy) responseEnv(r *http.Request, res *http.Response) Env {
+	headers := map[string]string{}
+
+	for key := range res.Header {
+		headers[key] = res.Header.Get(key)
+	}
+
+	took, _ := r.Context().Value(upstreamTimeKey{}).(time.Duration)
+	age, _ := strconv.Atoi(res.Header.Get("Age"))
+
+	return Env{
+		"status":  res.StatusCode,
+		"text":    http.StatusText(res.StatusCode),
+		"headers": headers,
+		"took":    took,
+		"age":     age,
+	}
+}
+
+// pageEnv collects page metadata from a backend response for layouts:
+// the language, links by relation and any X-Page-* headers.
+func (p *proxy) pageEnv(res *http.Response) Env {
+	page := Env{
+		"lang":  res.Header.Get("Content-Language"),
+		"links": parseLinks(res.Header.Values("Link")),
+	}
+
+	for key := range res.Header {
+		if strings.HasPrefix(key, pageHeaderPrefix) && len(key) > len(pageHeaderPrefix) {
+			name := strings.ToLower(key[len(pageHeaderPrefix):])
+			page[name] = res.Header.Get(key)
+		}
+	}
+
+	return page
+}
+
+// parseLinks maps each relation in Link headers to its URL, so a
+// canonical link is available as .page.links.canonical.
+func parseLinks(values []string) map[string]string {
+	links := map[string]string{}
+
+	for _, value := range values {
+		for _, link := range strings.Split(value, ",") {
+			parts := strings.Split(link, ";")
+			url := strings.TrimSpace(parts[0])
+
+			if !strings.HasPrefix(url, "<") || !strings.HasSuffix(url, ">") {
+				continue
+			}
+
+			for _, param := range parts[1:] {
+				kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+
+				if len(kv) != 2 || strings.ToLower(kv[0]) != "rel" {
+					continue
+				}
+
+				for _, rel := range strings.Fields(strings.Trim(kv[1], `"`)) {
+					links[strings.ToLower(rel)] = url[1 : len(url)-1]
+				}
+			}
+		}
+	}
+
+	return links
+}
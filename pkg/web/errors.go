@@ -4,6 +4,10 @@ import (
 	"net/http"
 )
 
+func http401(rw http.ResponseWriter, r *http.Request) {
+	http.Error(rw, "401 Unauthorized", http.StatusUnauthorized)
+}
+
 func http403(rw http.ResponseWriter, r *http.Request) {
 	http.Error(rw, "403 Forbidden", http.StatusForbidden)
 }
@@ -12,6 +16,30 @@ func http404(rw http.ResponseWriter, r *http.Request) {
 	http.Error(rw, "404 Not Found", http.StatusNotFound)
 }
 
+func http413(rw http.ResponseWriter, r *http.Request) {
+	http.Error(rw, "413 Request Entity Too Large", http.StatusRequestEntityTooLarge)
+}
+
+func http415(rw http.ResponseWriter, r *http.Request) {
+	http.Error(rw, "415 Unsupported Media Type", http.StatusUnsupportedMediaType)
+}
+
+func http429(rw http.ResponseWriter, r *http.Request) {
+	http.Error(rw, "429 Too Many Requests", http.StatusTooManyRequests)
+}
+
 func http500(rw http.ResponseWriter, r *http.Request) {
 	http.Error(rw, "500 Internal Server Error", http.StatusInternalServerError)
 }
+
+func http502(rw http.ResponseWriter, r *http.Request) {
+	http.Error(rw, "502 Bad Gateway", http.StatusBadGateway)
+}
+
+func http503(rw http.ResponseWriter, r *http.Request) {
+	http.Error(rw, "503 Service Unavailable", http.StatusServiceUnavailable)
+}
+
+func http504(rw http.ResponseWriter, r *http.Request) {
+	http.Error(rw, "504 Gateway Timeout", http.StatusGatewayTimeout)
+}
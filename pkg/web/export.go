@@ -0,0 +1,116 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+
+	"github.com/sats-group/abc/internal/files"
+)
+
+// AssetManifest names the file Export writes next to the asset bundles,
+// mapping each bundle to its sources.
+const AssetManifest = "manifest.json"
+
+// Export renders every page to a static file below target, copies the
+// remaining non-template files and writes asset bundles with a manifest.
+// Pages go through the full stack, so export with Prod set to bundle.
+func (w *Web) Export(target string) error {
+	pages, err := w.engine.pages()
+
+	if err != nil {
+		return err
+	}
+
+	for _, rel := range pages {
+		if err := w.exportPage(target, rel); err != nil {
+			return err
+		}
+	}
+
+	err = files.CopyTree(w.config.dir(), target, func(rel string) bool {
+		ext := filepath.Ext(rel)
+		return ext != w.config.frontendExt() && ext != w.config.backendExt() && !files.Excluded(filepath.ToSlash(rel))
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return w.assets.export(target)
+}
+
+func (w *Web) exportPage(target string, rel string) error {
+	rel = filepath.ToSlash(rel)
+	url := "/" + strings.TrimSuffix(rel, "index"+w.config.frontendExt())
+
+	rec := httptest.NewRecorder()
+	w.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+
+	if rec.Code != http.StatusOK {
+		return fmt.Errorf("export error: %s (%d)", url, rec.Code)
+	}
+
+	out := filepath.Join(target, filepath.FromSlash(rel))
+
+	if err := files.MkdirAll(filepath.Dir(out)); err != nil {
+		return err
+	}
+
+	return files.Write(out, rec.Body.Bytes())
+}
+
+// pages lists the frontend templates that are served as pages.
+func (e *engine) pages() ([]string, error) {
+	pages := []string{}
+
+	err := files.Walk(e.config.dir(), func(rel string) error {
+		rel = filepath.ToSlash(rel)
+
+		switch {
+		case filepath.Ext(rel) != e.config.frontendExt():
+		case files.Ignore(rel), files.Excluded(rel), isPartial(rel):
+		case e.templateName(rel) == e.config.layout():
+		default:
+			pages = append(pages, rel)
+		}
+
+		return nil
+	})
+
+	return pages, err
+}
+
+func (a *assets) export(target string) error {
+	dir := filepath.Join(target, filepath.FromSlash(a.prefix))
+	manifest := a.manifest()
+
+	for name, file := range a.files() {
+		if file.paths == nil {
+			continue
+		}
+
+		if err := files.MkdirAll(dir); err != nil {
+			return err
+		}
+
+		if err := files.Write(filepath.Join(dir, name), file.bytes); err != nil {
+			return err
+		}
+	}
+
+	if len(manifest) == 0 {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return files.Write(filepath.Join(dir, AssetManifest), b)
+}
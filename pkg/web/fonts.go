@@ -0,0 +1,77 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/sats-group/abc/pkg/tmpl"
+)
+
+var fontFormats = map[string][2]string{
+	".woff2": {"font/woff2", "woff2"},
+	".woff":  {"font/woff", "woff"},
+	".ttf":   {"font/ttf", "truetype"},
+	".otf":   {"font/otf", "opentype"},
+}
+
+var fontValue = regexp.MustCompile(`[^\w ,.\-]`)
+
+// font preloads a font file, with the crossorigin attribute fonts need
+// even from the same origin. Given a "family", it also emits @font-face
+// CSS with any "weight", "style" and "display" (default swap).
+func (a *assets) font(source string, pairs ...interface{}) (template.HTML, error) {
+	opts, err := tmpl.Dict(pairs...)
+
+	if err != nil {
+		return "", err
+	}
+
+	format, ok := fontFormats[strings.ToLower(path.Ext(source))]
+
+	if !ok {
+		return "", fmt.Errorf("unknown font format: %s", source)
+	}
+
+	href, err := a.assetURL(source)
+
+	if err != nil {
+		return "", err
+	}
+
+	tags := fmt.Sprintf(
+		"<link rel=\"preload\" href=\"%s\" as=\"font\" type=\"%s\" crossorigin>",
+		template.HTMLEscapeString(href), format[0],
+	)
+
+	family := fontOption(opts, "family", "")
+
+	if family == "" {
+		return template.HTML(tags), nil
+	}
+
+	face := fmt.Sprintf(
+		"@font-face{font-family:\"%s\";src:url(\"%s\") format(\"%s\");font-display:%s",
+		family, href, format[1], fontOption(opts, "display", "swap"),
+	)
+
+	for _, key := range []string{"weight", "style"} {
+		if val := fontOption(opts, key, ""); val != "" {
+			face += fmt.Sprintf(";font-%s:%s", key, val)
+		}
+	}
+
+	return template.HTML(tags + "\n<style>" + face + "}</style>"), nil
+}
+
+func fontOption(opts map[string]interface{}, key string, fallback string) string {
+	val, ok := opts[key]
+
+	if !ok {
+		return fallback
+	}
+
+	return fontValue.ReplaceAllString(fmt.Sprint(val), "")
+}
@@ -0,0 +1,296 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// A FormRule handles POSTs from HTML forms to paths under a prefix. The
+// form is forwarded to the backend, as JSON if set, and a successful
+// response redirects (Post/Redirect/Get) to the backend's Location on
+// the frontend, the Redirect path or back to the form, with an optional
+// flash message.
+type FormRule struct {
+	Path     string
+	JSON     bool
+	Redirect string
+	Flash    string
+}
+
+const (
+	flashKey    = "flash"
+	flashCookie = "flash"
+	errorsKey   = "errors"
+	valuesKey   = "values"
+)
+
+// maxFormSize limits form bodies buffered for forwarding.
+const maxFormSize = 32 << 20
+
+// secretFields match names of fields never echoed back into a form.
+var secretFields = regexp.MustCompile(`(?i)passw(or)?d|secret|token`)
+
+func (p *proxy) formRule(r *http.Request) *FormRule {
+	if r.Method != http.MethodPost || !isForm(r) {
+		return nil
+	}
+
+	for i, rule := range p.config.Forms {
+		if matchPath(rule.Path, r.URL.Path) {
+			return &p.config.Forms[i]
+		}
+	}
+
+	return nil
+}
+
+func isForm(r *http.Request) bool {
+	t, _, err := mime.ParseMediaType(r.Header.Get(contentTypeKey))
+	return err == nil && (t == "application/x-www-form-urlencoded" || t == "multipart/form-data")
+}
+
+// forwardForm sends the submitted form along to the backend, as a JSON
+// body with fields given more than once as lists if the rule says so.
+// It returns the submitted values for re-rendering the form, leaving
+// out files and secrets like passwords.
+func (p *proxy) forwardForm(req *http.Request, r *http.Request, rule *FormRule) (url.Values, error) {
+	raw, err := ioutil.ReadAll(io.LimitReader(r.Body, maxFormSize+1))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw) > maxFormSize {
+		return nil, errors.New("form too large")
+	}
+
+	r.Body = ioutil.NopCloser(bytes.NewReader(raw))
+
+	if err := r.ParseMultipartForm(maxFormSize); err != nil && err != http.ErrNotMultipart {
+		return nil, err
+	}
+
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+
+	form := r.PostForm
+	body := raw
+	req.Header.Set(contentTypeKey, r.Header.Get(contentTypeKey))
+
+	if rule.JSON {
+		data := map[string]interface{}{}
+
+		for key, vals := range form {
+			if len(vals) == 1 {
+				data[key] = vals[0]
+			} else {
+				data[key] = vals
+			}
+		}
+
+		if body, err = json.Marshal(data); err != nil {
+			return nil, err
+		}
+
+		req.Header.Set(contentTypeKey, "application/json")
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+
+	return echoed(r, form), nil
+}
+
+func echoed(r *http.Request, form url.Values) url.Values {
+	values := url.Values{}
+
+	for key, vals := range form {
+		if secretFields.MatchString(key) {
+			continue
+		}
+
+		if r.MultipartForm != nil && r.MultipartForm.File[key] != nil {
+			continue
+		}
+
+		values[key] = vals
+	}
+
+	return values
+}
+
+// decorateInvalid re-renders a form the backend rejected, with its field
+// errors under .errors and the submitted values under .values.
+func (p *proxy) decorateInvalid(rw http.ResponseWriter, r *http.Request, res *http.Response, tmpl string, form url.Values) error {
+	defer res.Body.Close()
+
+	if tmpl == "" {
+		tmpl = p.templatePath(r.URL.Path)
+	}
+
+	data := Env{}
+
+	if err := p.decodeResponse(res, &data); err != nil && err != io.EOF {
+		return err
+	}
+
+	values := map[string]string{}
+
+	for key := range form {
+		values[key] = form.Get(key)
+	}
+
+	data[errorsKey] = fieldErrors(data[errorsKey])
+	data[valuesKey] = values
+	data[responseKey] = p.responseEnv(r, res)
+
+	p.engine.respond(rw, r, res.StatusCode, tmpl, data)
+
+	return nil
+}
+
+// fieldErrors reads field errors given as {"field": "message"},
+// {"field": ["message", ...]} or [{"field": "...", "message": "..."}],
+// keeping the first message for each field.
+func fieldErrors(raw interface{}) map[string]string {
+	errs := map[string]string{}
+
+	switch raw := raw.(type) {
+	case map[string]interface{}:
+		for field, msg := range raw {
+			if list, ok := msg.([]interface{}); ok && len(list) > 0 {
+				msg = list[0]
+			}
+
+			if s, ok := msg.(string); ok {
+				errs[field] = s
+			}
+		}
+	case []interface{}:
+		for _, item := range raw {
+			obj, ok := item.(map[string]interface{})
+
+			if !ok {
+				continue
+			}
+
+			field, _ := obj["field"].(string)
+			msg, _ := obj["message"].(string)
+
+			if _, seen := errs[field]; field != "" && !seen {
+				errs[field] = msg
+			}
+		}
+	}
+
+	return errs
+}
+
+// redirect completes a successful or redirected form POST with a
+// redirect to a page that can be safely reloaded.
+func (p *proxy) redirect(rw http.ResponseWriter, r *http.Request, res *http.Response, rule *FormRule) {
+	_ = res.Body.Close()
+
+	to := frontendLocation(res)
+
+	if to == "" {
+		to = rule.Redirect
+	}
+
+	if to == "" {
+		to = localReferer(r)
+	}
+
+	if rule.Flash != "" {
+		p.cookies.set(rw, &http.Cookie{Name: flashCookie, HttpOnly: true}, p.cookies.signed(flashCookie, rule.Flash))
+	}
+
+	http.Redirect(rw, r, to, http.StatusSeeOther)
+}
+
+// frontendLocation returns the backend's Location as a path on the
+// frontend. Absolute URLs are only kept when they point at the backend
+// itself, so forms never redirect elsewhere.
+func frontendLocation(res *http.Response) string {
+	u, err := url.Parse(res.Header.Get("Location"))
+
+	if err != nil || (u.Path == "" && u.Host == "") {
+		return ""
+	}
+
+	if res.Request != nil {
+		if u.Host != "" && u.Host != res.Request.URL.Host {
+			return ""
+		}
+
+		u = res.Request.URL.ResolveReference(u)
+	} else if u.IsAbs() || u.Host != "" || !strings.HasPrefix(u.Path, "/") {
+		return ""
+	}
+
+	if !localPath(u.Path) {
+		return ""
+	}
+
+	return (&url.URL{Path: u.Path, RawQuery: u.RawQuery, Fragment: u.Fragment}).String()
+}
+
+// localReferer returns the path of a same-site Referer, or the request
+// path, so forms never redirect elsewhere.
+func localReferer(r *http.Request) string {
+	u, err := url.Parse(r.Referer())
+
+	if err != nil || u.Path == "" || (u.Host != "" && u.Host != r.Host) || !localPath(u.Path) {
+		return r.URL.Path
+	}
+
+	if u.RawQuery != "" {
+		return u.Path + "?" + u.RawQuery
+	}
+
+	return u.Path
+}
+
+// localPath reports if a path redirects within the site, unlike ones
+// browsers read as a host like "//evil.com" and "/\evil.com".
+func localPath(p string) bool {
+	return !strings.HasPrefix(p, "//") && !strings.HasPrefix(p, "/\\")
+}
+
+// noFollow returns a copy of a client handing redirects back instead of
+// following them, so form posts see the backend's Location.
+func noFollow(c *http.Client) *http.Client {
+	nf := *c
+	nf.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &nf
+}
+
+// flash reads and clears a flash message set by a form redirect.
+func (cs *cookies) flash(rw http.ResponseWriter, r *http.Request) string {
+	c, err := r.Cookie(flashCookie)
+
+	if err != nil {
+		return ""
+	}
+
+	cs.set(rw, &http.Cookie{Name: flashCookie, MaxAge: -1, HttpOnly: true}, "")
+	msg, err := cs.unsigned(flashCookie, c.Value)
+
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(msg)
+}
@@ -0,0 +1,58 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"time"
+)
+
+const fragmentPrefix = "fragment:"
+
+// cacheFunc creates the {{cache "key" "ttl" "template" data "tags"...}}
+// func, rendering a template once and reusing it until it expires or
+// one of its tags, or any path it was served for, is purged. Fragments
+// are only cached in prod, keyed by the request's variant. CSP nonces
+// are swapped for each request.
+func (e *engine) cacheFunc(ctx context.Context, ts Templates, funcs template.FuncMap) func(string, string, string, interface{}, ...string) (template.HTML, error) {
+	return func(key, ttl, name string, data interface{}, tags ...string) (template.HTML, error) {
+		if v := variantFrom(ctx); v != "" {
+			key += "|" + v
+		}
+
+		nonce := Nonce(ctx)
+		d, err := time.ParseDuration(ttl)
+
+		if err != nil {
+			return "", err
+		}
+
+		if b, ok := e.store.Get(fragmentPrefix + key); ok && e.config.prod() {
+			e.stats.fragment(true)
+			traceCache(ctx, key, true)
+			e.indexFragment(ctx, key, d)
+
+			return template.HTML(renonce(b, nonce, e.nonceMark)), nil
+		}
+
+		e.stats.fragment(false)
+		traceCache(ctx, key, false)
+
+		buf := new(bytes.Buffer)
+
+		if err := ts.Execute(&ctxWriter{ctx: ctx, w: buf}, name, data, funcs); err != nil {
+			return "", err
+		}
+
+		if e.config.prod() {
+			e.store.Set(fragmentPrefix+key, unnonce(buf.Bytes(), nonce, e.nonceMark), d, renderTags(ctx, tags)...)
+			e.indexFragment(ctx, key, d)
+		}
+
+		return template.HTML(buf.String()), nil
+	}
+}
+
+func cachePlaceholder(key, ttl, name string, data interface{}, tags ...string) (template.HTML, error) {
+	return "", nil
+}
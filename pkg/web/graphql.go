@@ -0,0 +1,144 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/sats-group/abc/internal/files"
+)
+
+type graphqlRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+type graphqlResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []interface{}          `json:"errors"`
+}
+
+// GraphQL adds a route rendering a template with the result of a query
+// file run against the backend. Route params and query string values
+// are passed as variables.
+func (w *Web) GraphQL(method string, path string, file string, query string) {
+	if w.proxy == nil {
+		log.Fatalf("graphql requires a backend: %s\n", path)
+	}
+
+	tmpl := strings.TrimPrefix(file, "/")
+
+	if filepath.Ext(tmpl) == "" {
+		tmpl += w.config.backendExt()
+	}
+
+	source := filepath.Join(w.config.dir(), query)
+	doc, err := files.ReadE(source)
+
+	if err != nil {
+		log.Fatalf("unknown file: %s\n", source)
+	}
+
+	w.HandlerFunc(method, path, func(rw http.ResponseWriter, r *http.Request, p Params) {
+		q := string(doc)
+
+		if !w.config.prod() {
+			b, err := files.ReadE(source)
+
+			if err != nil {
+				log.Println(source, err)
+				http500(rw, r)
+				return
+			}
+
+			q = string(b)
+		}
+
+		w.proxy.graphql(rw, r, p, tmpl, q)
+	})
+}
+
+func (p *proxy) graphql(rw http.ResponseWriter, r *http.Request, params Params, tmpl string, doc string) {
+	res, err := p.graphqlQuery(r, doc, graphqlVariables(r, params))
+
+	if err != nil && r.Context().Err() != nil {
+		return
+	}
+
+	if err != nil {
+		log.Println(tmpl, err)
+		http502(rw, r)
+		return
+	}
+
+	data := Env(res.Data)
+
+	if data == nil {
+		data = Env{}
+	}
+
+	data[responseKey] = Env{"errors": res.Errors}
+	p.engine.respond(rw, r, http.StatusOK, tmpl, data)
+}
+
+func (p *proxy) graphqlQuery(r *http.Request, doc string, vars map[string]interface{}) (*graphqlResponse, error) {
+	body, err := json.Marshal(graphqlRequest{Query: doc, Variables: vars})
+
+	if err != nil {
+		return nil, err
+	}
+
+	base, report := p.backendURL(r)
+	url := base + p.config.graphql()
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, url, bytes.NewReader(body))
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set(contentTypeKey, "application/json")
+	if err := p.signBackend(req); err != nil {
+		return nil, err
+	}
+
+	res, err := p.do(req)
+	report(res, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	out := &graphqlResponse{}
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return nil, err
+	}
+
+	if out.Data == nil && len(out.Errors) > 0 {
+		return nil, fmt.Errorf("graphql errors: %v", out.Errors)
+	}
+
+	return out, nil
+}
+
+func graphqlVariables(r *http.Request, params Params) map[string]interface{} {
+	vars := map[string]interface{}{}
+
+	for key, vals := range r.URL.Query() {
+		if len(vals) > 0 {
+			vars[key] = vals[0]
+		}
+	}
+
+	for _, param := range params.Params {
+		vars[param.Key] = strings.TrimPrefix(param.Value, "/")
+	}
+
+	return vars
+}
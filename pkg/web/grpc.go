@@ -0,0 +1,330 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sats-group/abc/internal/files"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// A transcoder turns HTTP requests into gRPC calls on the backend,
+// using message types from a compiled descriptor set.
+type transcoder struct {
+	config  *Config
+	engine  *engine
+	files   *protoregistry.Files
+	conn    *grpc.ClientConn
+	signing *Signing
+}
+
+var grpcStatus = map[codes.Code]int{
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.FailedPrecondition: http.StatusPreconditionFailed,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+}
+
+// GRPC adds a route calling a gRPC method ("package.Service/Method") on
+// the backend. The request message is built from the JSON body, route
+// params and query string, and the response is rendered with a template.
+func (w *Web) GRPC(method string, path string, file string, rpc string) {
+	if w.grpc == nil {
+		w.grpc = w.newTranscoder()
+	}
+
+	md := w.grpc.method(rpc)
+	tmpl := strings.TrimPrefix(file, "/")
+
+	if filepath.Ext(tmpl) == "" {
+		tmpl += w.config.backendExt()
+	}
+
+	w.HandlerFunc(method, path, func(rw http.ResponseWriter, r *http.Request, p Params) {
+		w.grpc.serve(rw, r, p, md, tmpl)
+	})
+}
+
+func (w *Web) newTranscoder() *transcoder {
+	if w.config.Descriptors == "" || w.config.backend() == "" {
+		log.Fatalln("grpc requires a backend and descriptors")
+	}
+
+	source := w.config.Descriptors
+
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(w.config.dir(), source)
+	}
+
+	fds := &descriptorpb.FileDescriptorSet{}
+	data, err := files.ReadE(source)
+
+	if err != nil {
+		log.Fatalf("unknown file: %s\n", source)
+	}
+
+	if err := proto.Unmarshal(data, fds); err != nil {
+		log.Fatalf("parse error: %s\n", err)
+	}
+
+	reg, err := protodesc.NewFiles(fds)
+
+	if err != nil {
+		log.Fatalf("parse error: %s\n", err)
+	}
+
+	u, err := url.Parse(w.config.backend())
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	creds := insecure.NewCredentials()
+
+	if u.Scheme == "https" {
+		conf := w.config.Signing.tlsConfig()
+		conf.ServerName = u.Hostname()
+		creds = credentials.NewTLS(conf)
+	}
+
+	conn, err := grpc.NewClient(u.Host, grpc.WithTransportCredentials(creds))
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	return &transcoder{
+		config:  w.config,
+		engine:  w.engine,
+		files:   reg,
+		conn:    conn,
+		signing: w.config.Signing,
+	}
+}
+
+func (t *transcoder) method(rpc string) protoreflect.MethodDescriptor {
+	rpc = strings.TrimPrefix(rpc, "/")
+	slash := strings.LastIndex(rpc, "/")
+
+	if slash == -1 {
+		log.Fatalf("invalid grpc method: %s\n", rpc)
+	}
+
+	d, err := t.files.FindDescriptorByName(protoreflect.FullName(rpc[:slash]))
+
+	if err != nil {
+		log.Fatalf("unknown grpc service: %s\n", rpc)
+	}
+
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+
+	if !ok {
+		log.Fatalf("unknown grpc service: %s\n", rpc)
+	}
+
+	md := sd.Methods().ByName(protoreflect.Name(rpc[slash+1:]))
+
+	if md == nil || md.IsStreamingClient() || md.IsStreamingServer() {
+		log.Fatalf("unknown or streaming grpc method: %s\n", rpc)
+	}
+
+	return md
+}
+
+func (t *transcoder) serve(rw http.ResponseWriter, r *http.Request, params Params, md protoreflect.MethodDescriptor, tmpl string) {
+	in, err := t.input(rw, r, params, md.Input())
+	var tooLarge *http.MaxBytesError
+
+	if errors.As(err, &tooLarge) {
+		http413(rw, r)
+		return
+	}
+
+	if err != nil {
+		log.Println(md.FullName(), err)
+		http.Error(rw, "400 Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	out := dynamicpb.NewMessage(md.Output())
+	name := "/" + string(md.Parent().FullName()) + "/" + string(md.Name())
+	ctx, err := t.sign(r.Context(), name, in)
+
+	if err != nil {
+		log.Println(name, err)
+		http500(rw, r)
+		return
+	}
+
+	if err := t.conn.Invoke(ctx, name, in, out); err != nil {
+		if r.Context().Err() == nil {
+			t.fail(rw, r, err)
+		}
+
+		return
+	}
+
+	b, err := protojson.Marshal(out)
+	data := Env{}
+
+	if err == nil {
+		err = json.Unmarshal(b, &data)
+	}
+
+	if err != nil {
+		log.Println(name, err)
+		http502(rw, r)
+		return
+	}
+
+	t.engine.respond(rw, r, http.StatusOK, tmpl, data)
+}
+
+// sign authenticates a call with the backend's Signing, if any.
+func (t *transcoder) sign(ctx context.Context, name string, in proto.Message) (context.Context, error) {
+	if t.signing == nil {
+		return ctx, nil
+	}
+
+	msg, err := proto.Marshal(in)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return metadata.NewOutgoingContext(ctx, t.signing.metadata(name, msg)), nil
+}
+
+// maxMessageSize limits JSON bodies transcoded into messages, matching
+// the size gRPC servers accept by default.
+const maxMessageSize = 4 << 20
+
+func (t *transcoder) input(rw http.ResponseWriter, r *http.Request, params Params, desc protoreflect.MessageDescriptor) (proto.Message, error) {
+	in := dynamicpb.NewMessage(desc)
+	body, err := ioutil.ReadAll(http.MaxBytesReader(rw, r.Body, maxMessageSize))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(body) > 0 {
+		if err := protojson.Unmarshal(body, in); err != nil {
+			return nil, err
+		}
+	}
+
+	vals := map[string]string{}
+
+	for key, val := range r.URL.Query() {
+		vals[key] = val[0]
+	}
+
+	for _, p := range params.Params {
+		vals[p.Key] = strings.TrimPrefix(p.Value, "/")
+	}
+
+	fields := desc.Fields()
+
+	for key, val := range vals {
+		fd := fields.ByJSONName(key)
+
+		if fd == nil {
+			fd = fields.ByName(protoreflect.Name(key))
+		}
+
+		if fd == nil || fd.IsList() || fd.IsMap() || fd.Message() != nil {
+			continue
+		}
+
+		v, err := scalarValue(fd, val)
+
+		if err != nil {
+			return nil, err
+		}
+
+		in.Set(fd, v)
+	}
+
+	return in, nil
+}
+
+func (t *transcoder) fail(rw http.ResponseWriter, r *http.Request, err error) {
+	st := status.Convert(err)
+	code, ok := grpcStatus[st.Code()]
+
+	if !ok {
+		code = http.StatusBadGateway
+	}
+
+	if tmpl, ok := t.config.errorTemplate(code); ok && tmpl != rawErrors {
+		t.engine.respond(rw, r, code, tmpl, Env{"status": code, "message": st.Message()})
+		return
+	}
+
+	http.Error(rw, strconv.Itoa(code)+" "+st.Message(), code)
+}
+
+func scalarValue(fd protoreflect.FieldDescriptor, val string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(val), nil
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte(val)), nil
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(val)
+		return protoreflect.ValueOfBool(b), err
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(val)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+
+		n, err := strconv.ParseInt(val, 10, 32)
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(val, 10, 32)
+		return protoreflect.ValueOfInt32(int32(n)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(val, 10, 64)
+		return protoreflect.ValueOfInt64(n), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(val, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(n)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(val, 10, 64)
+		return protoreflect.ValueOfUint64(n), err
+	case protoreflect.FloatKind:
+		n, err := strconv.ParseFloat(val, 32)
+		return protoreflect.ValueOfFloat32(float32(n)), err
+	case protoreflect.DoubleKind:
+		n, err := strconv.ParseFloat(val, 64)
+		return protoreflect.ValueOfFloat64(n), err
+	}
+
+	return protoreflect.Value{}, nil
+}
@@ -0,0 +1,68 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/codegangsta/negroni"
+)
+
+// A HeaderRule sets and removes response headers for matching paths.
+type HeaderRule struct {
+	Path   string
+	Set    map[string]string
+	Remove []string
+}
+
+type headerWriter struct {
+	ResponseWriter
+	rules []HeaderRule
+	path  string
+	wrote bool
+}
+
+func (w *Web) newHeaders() Middleware {
+	rules := w.config.Headers
+
+	if len(rules) == 0 {
+		return nil
+	}
+
+	fn := func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		next(&headerWriter{ResponseWriter: NewResponseWriter(rw), rules: rules, path: r.URL.Path}, r)
+	}
+
+	return negroni.HandlerFunc(fn)
+}
+
+func (hw *headerWriter) WriteHeader(status int) {
+	if !hw.wrote {
+		hw.wrote = true
+		hw.apply(hw.Header())
+	}
+
+	hw.ResponseWriter.WriteHeader(status)
+}
+
+func (hw *headerWriter) Write(b []byte) (int, error) {
+	if !hw.wrote {
+		hw.WriteHeader(http.StatusOK)
+	}
+
+	return hw.ResponseWriter.Write(b)
+}
+
+func (hw *headerWriter) apply(h http.Header) {
+	for _, rule := range hw.rules {
+		if !matchPath(rule.Path, hw.path) {
+			continue
+		}
+
+		for _, key := range rule.Remove {
+			h.Del(key)
+		}
+
+		for key, val := range rule.Set {
+			h.Set(key, val)
+		}
+	}
+}
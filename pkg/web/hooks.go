@@ -0,0 +1,137 @@
+package web
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+)
+
+// lifecycle holds the hooks run as the server starts and stops.
+type lifecycle struct {
+	start    []func() error
+	ready    []func() error
+	shutdown []func(context.Context) error
+}
+
+// OnStart adds a hook run by Serve before it listens. An error stops the
+// server from starting.
+func (w *Web) OnStart(fn func() error) {
+	w.hooks.start = append(w.hooks.start, fn)
+}
+
+// OnReady adds a hook run in the background once Serve accepts
+// connections, like registering with service discovery.
+func (w *Web) OnReady(fn func() error) {
+	w.hooks.ready = append(w.hooks.ready, fn)
+}
+
+// OnShutdown adds a hook run by Shutdown after requests and jobs have
+// finished, like flushing logs.
+func (w *Web) OnShutdown(fn func(context.Context) error) {
+	w.hooks.shutdown = append(w.hooks.shutdown, fn)
+}
+
+// OnTemplateCompile adds a hook run each time templates are compiled,
+// with the time it took and any error.
+func (w *Web) OnTemplateCompile(fn func(took time.Duration, err error)) {
+	w.engine.compileHook(fn)
+}
+
+// OnAssetBuild adds a hook run each time an asset bundle is built, with
+// its name, size and the time it took.
+func (w *Web) OnAssetBuild(fn func(name string, size int, took time.Duration)) {
+	w.assets.buildHooks = append(w.assets.buildHooks, fn)
+}
+
+func (l *lifecycle) started() error {
+	for _, fn := range l.start {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *lifecycle) readied() {
+	for _, fn := range l.ready {
+		if err := fn(); err != nil {
+			log.Println("ready:", err)
+		}
+	}
+}
+
+func (l *lifecycle) stopped(ctx context.Context) error {
+	var first error
+
+	for _, fn := range l.shutdown {
+		if err := fn(ctx); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// OnProxyRequest adds a hook run on every request to the backend before
+// it is sent. Hooks may change the request, and an error fails it.
+func (w *Web) OnProxyRequest(fn func(*http.Request) error) {
+	if w.proxy == nil {
+		log.Fatalln("proxy hooks require a backend")
+	}
+
+	w.proxy.requestHooks = append(w.proxy.requestHooks, fn)
+}
+
+// OnProxyResponse adds a hook run on every backend response before it is
+// decorated. Hooks may change the response, and an error fails it.
+func (w *Web) OnProxyResponse(fn func(*http.Response) error) {
+	if w.proxy == nil {
+		log.Fatalln("proxy hooks require a backend")
+	}
+
+	w.proxy.responseHooks = append(w.proxy.responseHooks, fn)
+}
+
+func (p *proxy) beforeRequest(req *http.Request) error {
+	for _, fn := range p.requestHooks {
+		if err := fn(req); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *proxy) afterResponse(res *http.Response) error {
+	for _, fn := range p.responseHooks {
+		if err := fn(res); err != nil {
+			_ = res.Body.Close()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// do sends a request to the backend through the proxy hooks.
+func (p *proxy) do(req *http.Request) (*http.Response, error) {
+	if err := p.beforeRequest(req); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	res, err := p.client.Do(req)
+	trackUpstream(req.Context(), start)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.afterResponse(res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,183 @@
+package web
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"image"
+	"image/png"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	// Source icons may be JPEG as well as PNG.
+	_ "image/jpeg"
+
+	"github.com/sats-group/abc/internal/files"
+	"golang.org/x/image/draw"
+)
+
+// Icons generated from the source icon, with the relation of their link
+// tags. Icons without a relation are only listed in the manifest.
+var iconSizes = []struct {
+	name string
+	size int
+	rel  string
+}{
+	{"favicon-32x32.png", 32, "icon"},
+	{"favicon-16x16.png", 16, "icon"},
+	{"apple-touch-icon.png", 180, "apple-touch-icon"},
+	{"icon-192x192.png", 192, ""},
+	{"icon-512x512.png", 512, ""},
+}
+
+const (
+	iconRoot     = "/icons/"
+	manifestFile = "site.webmanifest"
+)
+
+type icons struct {
+	root  string
+	time  time.Time
+	files map[string][]byte
+	tags  template.HTML
+}
+
+func (w *Web) newIcons() *icons {
+	w.FuncMap(template.FuncMap{"icons": func() template.HTML { return "" }})
+
+	source := filepath.Join(w.config.dir(), w.config.icon())
+
+	if w.config.Icon == "" && !files.HasFile(source) {
+		return nil
+	}
+
+	i, err := generateIcons(source, w.config.frontendPath(), w.config.AppName)
+
+	if err != nil {
+		log.Fatalf("icon error: %s\n", err)
+	}
+
+	for name := range i.files {
+		route := iconRoot + name
+
+		if name == "favicon.ico" || name == manifestFile {
+			route = "/" + name
+		}
+
+		w.Handler("get", route, i)
+	}
+
+	w.FuncMap(template.FuncMap{"icons": func() template.HTML { return i.tags }})
+
+	return i
+}
+
+func (i *icons) ServeHTTP(rw http.ResponseWriter, r *http.Request, _ Params) {
+	name := path.Base(r.URL.Path)
+	b, ok := i.files[name]
+
+	if !ok {
+		http404(rw, r)
+		return
+	}
+
+	if name == manifestFile {
+		rw.Header().Set(contentTypeKey, "application/manifest+json")
+	}
+
+	http.ServeContent(rw, r, name, i.time, bytes.NewReader(b))
+}
+
+func generateIcons(source string, root string, name string) (*icons, error) {
+	f, err := os.Open(source)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	src, _, err := image.Decode(f)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", source, err)
+	}
+
+	i := &icons{root: root, time: time.Now(), files: map[string][]byte{}}
+	tags := []string{}
+	listed := []map[string]string{}
+
+	for _, s := range iconSizes {
+		b, err := resizePNG(src, s.size)
+
+		if err != nil {
+			return nil, err
+		}
+
+		href := path.Join(root, iconRoot, s.name)
+		sizes := fmt.Sprintf("%dx%d", s.size, s.size)
+		i.files[s.name] = b
+
+		if s.rel != "" {
+			tags = append(tags, fmt.Sprintf("<link rel=\"%s\" type=\"image/png\" sizes=\"%s\" href=\"%s\">", s.rel, sizes, href))
+		}
+
+		if s.size >= 192 {
+			listed = append(listed, map[string]string{"src": href, "sizes": sizes, "type": "image/png"})
+		}
+	}
+
+	i.files["favicon.ico"] = pngICO(i.files["favicon-32x32.png"], 32)
+
+	manifest, err := json.MarshalIndent(map[string]interface{}{
+		"name":       name,
+		"short_name": name,
+		"icons":      listed,
+		"display":    "standalone",
+		"start_url":  root + "/",
+	}, "", "  ")
+
+	if err != nil {
+		return nil, err
+	}
+
+	i.files[manifestFile] = manifest
+	tags = append(tags, fmt.Sprintf("<link rel=\"manifest\" href=\"%s\">", path.Join(root, "/", manifestFile)))
+	i.tags = template.HTML(strings.Join(tags, "\n"))
+
+	return i, nil
+}
+
+func resizePNG(src image.Image, size int) ([]byte, error) {
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Over, nil)
+
+	buf := new(bytes.Buffer)
+
+	if err := png.Encode(buf, dst); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// pngICO wraps a PNG image in a single-entry ICO file.
+func pngICO(b []byte, size int) []byte {
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.LittleEndian, [3]uint16{0, 1, 1})
+	_ = binary.Write(buf, binary.LittleEndian, struct {
+		Width, Height, Colors, Reserved uint8
+		Planes, Bits                    uint16
+		Size, Offset                    uint32
+	}{uint8(size), uint8(size), 0, 0, 1, 32, uint32(len(b)), 22})
+	buf.Write(b)
+
+	return buf.Bytes()
+}
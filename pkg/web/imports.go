@@ -0,0 +1,166 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	cssImport = regexp.MustCompile(`@import\s+(?:url\(\s*)?["']?([^"')\s;]+)["']?\s*\)?\s*([^;]*);`)
+	jsImport  = regexp.MustCompile(`((?:import|export)\s[^'";]*?\bfrom\s*|\bimport\s*\(?\s*)(["'])(\.{1,2}/[^"']+)(["'])`)
+	jsModule  = regexp.MustCompile(`(?m)^\s*(?:import|export)[\s{*]`)
+)
+
+var moduleJS = &assetType{
+	name: "js",
+	ext:  ".js",
+	html: "<script type=\"module\" src=\"%s\">%s</script>",
+}
+
+// module reports if a script is an ES module, which must be loaded with
+// type="module" and not concatenated with others that may declare the
+// same names. Prod remembers the answer per file.
+func (a *assets) module(rel string) bool {
+	a.mu.RLock()
+	known, ok := a.modules[rel]
+	a.mu.RUnlock()
+
+	if ok && a.prod {
+		return known
+	}
+
+	src := &bytes.Buffer{}
+	is := a.bufferFromPath(http.Dir(a.dir), rel, src) == nil && jsModule.Match(src.Bytes())
+
+	a.mu.Lock()
+	a.modules[rel] = is
+	a.mu.Unlock()
+
+	return is
+}
+
+// splitModules separates ES modules from classic scripts.
+func (a *assets) splitModules(rels []string) ([]string, []string) {
+	classic, modules := []string{}, []string{}
+
+	for _, rel := range rels {
+		if a.module(rel) {
+			modules = append(modules, rel)
+		} else {
+			classic = append(classic, rel)
+		}
+	}
+
+	return classic, modules
+}
+
+// bufferImports adds a file to a bundle. Relative CSS imports are inlined,
+// since the bundle lives elsewhere, and relative ES module imports point
+// to the public path of the imported file instead.
+func (a *assets) bufferImports(dir http.FileSystem, rel string, buf *bytes.Buffer) error {
+	switch path.Ext(rel) {
+	case css.ext:
+		b, err := a.inlineCSS(dir, rel, map[string]bool{})
+
+		if err != nil {
+			return err
+		}
+
+		_, err = buf.Write(b)
+
+		return err
+	case js.ext:
+		src := &bytes.Buffer{}
+
+		if err := a.bufferFromPath(dir, rel, src); err != nil {
+			return err
+		}
+
+		_, err := buf.Write(a.rewriteJS(rel, src.Bytes()))
+
+		return err
+	}
+
+	return a.bufferFromPath(dir, rel, buf)
+}
+
+func (a *assets) inlineCSS(dir http.FileSystem, rel string, seen map[string]bool) ([]byte, error) {
+	if seen[rel] {
+		return nil, fmt.Errorf("import cycle: %s", rel)
+	}
+
+	seen[rel] = true
+	defer delete(seen, rel)
+
+	src := &bytes.Buffer{}
+
+	if err := a.bufferFromPath(dir, rel, src); err != nil {
+		return nil, err
+	}
+
+	var failed error
+
+	out := cssImport.ReplaceAllFunc(src.Bytes(), func(m []byte) []byte {
+		parts := cssImport.FindSubmatch(m)
+		target, media := string(parts[1]), strings.TrimSpace(string(parts[2]))
+
+		if failed != nil || !relativeURL(target) {
+			return m
+		}
+
+		b, err := a.inlineCSS(dir, path.Join(path.Dir(rel), target), seen)
+
+		if err != nil {
+			failed = err
+			return m
+		}
+
+		if media != "" {
+			return []byte("@media " + media + " {\n" + string(b) + "\n}")
+		}
+
+		return b
+	})
+
+	if failed != nil {
+		return nil, failed
+	}
+
+	return a.rewriteURLs(dir, rel, out), nil
+}
+
+func (a *assets) rewriteJS(rel string, b []byte) []byte {
+	base := path.Join("/", a.root, path.Dir(rel))
+
+	return jsImport.ReplaceAllFunc(b, func(m []byte) []byte {
+		parts := jsImport.FindSubmatch(m)
+		target := path.Join(base, string(parts[3]))
+
+		return []byte(string(parts[1]) + string(parts[2]) + target + string(parts[4]))
+	})
+}
+
+// relativeURL reports whether a url points to a sibling file, rather than
+// another host, the site root or inline data.
+func relativeURL(url string) bool {
+	return url != "" && !strings.HasPrefix(url, "/") && !strings.HasPrefix(url, "#") && !strings.Contains(url, ":")
+}
+
+// hoistImports moves the imports left in a stylesheet bundle to its top,
+// where CSS requires them to be.
+func hoistImports(b []byte) []byte {
+	imports := cssImport.FindAll(b, -1)
+
+	if len(imports) == 0 {
+		return b
+	}
+
+	out := bytes.Join(imports, []byte("\n"))
+	out = append(out, '\n')
+
+	return append(out, cssImport.ReplaceAll(b, nil)...)
+}
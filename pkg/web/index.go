@@ -0,0 +1,133 @@
+package web
+
+import (
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/codegangsta/negroni"
+	"github.com/sats-group/abc/internal/files"
+)
+
+// An IndexRule lists the files of directories below a path, rendered
+// with a template or a plain default. Protect it with Auth like any
+// other path.
+type IndexRule struct {
+	Path     string
+	Template string
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!doctype html>
+<html><head><meta charset="utf-8"><title>Index of {{.path}}</title>
+<style>body{font:14px/1.5 sans-serif;margin:2em}td{padding:.2em 1em .2em 0}td+td{color:#666}</style>
+</head><body><h1>Index of {{.path}}</h1><table>
+{{if ne .path "/"}}<tr><td><a href="../">../</a></td></tr>{{end}}
+{{range .entries}}<tr><td><a href="{{.href}}">{{.name}}</a></td><td>{{if not .dir}}{{.size}}{{end}}</td><td>{{.modified.Format "2006-01-02 15:04"}}</td></tr>
+{{end}}</table></body></html>
+`))
+
+func (w *Web) newIndex() Middleware {
+	rules := w.config.Indexes
+
+	if len(rules) == 0 {
+		return nil
+	}
+
+	fn := func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		p := path.Clean("/" + r.URL.Path)
+		rule := w.indexRule(p)
+		dir := filepath.Join(w.config.dir(), filepath.FromSlash(p))
+
+		if rule == nil || r.Method != http.MethodGet || !files.HasDir(dir) {
+			next(rw, r)
+			return
+		}
+
+		if p != "/" {
+			p += "/"
+		}
+
+		// Redirect unclean paths too, so that Auth sees the path listed.
+		if r.URL.Path != p {
+			http.Redirect(rw, r, p, http.StatusMovedPermanently)
+			return
+		}
+
+		entries, err := w.indexEntries(p, dir)
+
+		if err != nil {
+			log.Println(err)
+			http500(rw, r)
+			return
+		}
+
+		env := Env{"path": p, "entries": entries}
+
+		if rule.Template != "" {
+			w.engine.respond(rw, r, http.StatusOK, strings.TrimPrefix(rule.Template, "/"), env)
+			return
+		}
+
+		rw.Header().Set(contentTypeKey, contentTypeVal)
+
+		if err := indexTemplate.Execute(rw, env); err != nil {
+			log.Println(err)
+		}
+	}
+
+	return negroni.HandlerFunc(fn)
+}
+
+func (w *Web) indexRule(p string) *IndexRule {
+	for i, rule := range w.config.Indexes {
+		if matchPath(rule.Path, p) {
+			return &w.config.Indexes[i]
+		}
+	}
+
+	return nil
+}
+
+// indexEntries lists a directory, folders first, leaving out anything
+// that is never served.
+func (w *Web) indexEntries(p string, dir string) ([]Env, error) {
+	infos, err := ioutil.ReadDir(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].IsDir() && !infos[j].IsDir()
+	})
+
+	entries := []Env{}
+
+	for _, info := range infos {
+		name := info.Name()
+		href := path.Join(p, name)
+
+		if info.IsDir() {
+			href += "/"
+		}
+
+		if strings.HasPrefix(name, ".") || files.Ignore(href) || files.Excluded(strings.TrimPrefix(href, "/")) || w.config.staticDenied(href) {
+			continue
+		}
+
+		entries = append(entries, Env{
+			"name":     name,
+			"href":     href,
+			"dir":      info.IsDir(),
+			"size":     info.Size(),
+			"modified": info.ModTime(),
+		})
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,92 @@
+package web
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// jobs run periodic tasks in the background until shutdown.
+type jobs struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func (w *Web) newJobs() *jobs {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &jobs{ctx: ctx, cancel: cancel}
+}
+
+// Every runs fn in the background once per interval, with up to a tenth
+// of it added at random so instances started together spread out. Runs
+// never overlap, panics and errors are logged, and the context passed
+// to fn is canceled on Shutdown.
+func (w *Web) Every(every time.Duration, fn func(context.Context) error) {
+	if every <= 0 {
+		log.Fatalf("invalid job interval: %s\n", every)
+	}
+
+	j := w.jobs
+	j.wg.Add(1)
+
+	go func() {
+		defer j.wg.Done()
+
+		for {
+			timer := time.NewTimer(every + jitter(every/10))
+
+			select {
+			case <-j.ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			j.run(fn)
+		}
+	}()
+}
+
+func (j *jobs) run(fn func(context.Context) error) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("job panic: %s\n%s", err, debug.Stack())
+		}
+	}()
+
+	if err := fn(j.ctx); err != nil && j.ctx.Err() == nil {
+		log.Println("job:", err)
+	}
+}
+
+// stop cancels the jobs and waits for running ones to return, or for
+// ctx to be done.
+func (j *jobs) stop(ctx context.Context) error {
+	j.cancel()
+
+	done := make(chan struct{})
+
+	go func() {
+		j.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(max)))
+}
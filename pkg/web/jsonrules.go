@@ -0,0 +1,162 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/sats-group/abc/internal/files"
+)
+
+const redacted = "[redacted]"
+
+// recordSeparator starts each record of an application/json-seq stream.
+const recordSeparator = 0x1e
+
+// A JSONRule transforms JSON passed through undecorated for matching
+// paths. Remove drops fields and Redact masks their values, both given
+// as dotted paths where "*" matches any key or array index, such as
+// "user.password" or "items.*.internal". Minify skips pretty-printing.
+type JSONRule struct {
+	Path   string
+	Remove []string
+	Redact []string
+	Minify bool
+}
+
+func (c *Config) jsonRule(p string) *JSONRule {
+	for i, rule := range c.JSONRules {
+		if matchPath(rule.Path, p) {
+			return &c.JSONRules[i]
+		}
+	}
+
+	return nil
+}
+
+// transformJSON applies a rule to a JSON document and encodes the result.
+func (p *proxy) transformJSON(rw http.ResponseWriter, res *http.Response, rule *JSONRule, body *files.Buffer) error {
+	var doc interface{}
+
+	if err := decodeBody(body, &doc, true); err != nil {
+		return err
+	}
+
+	return p.writeJSON(rw, res, rule, doc)
+}
+
+func (p *proxy) writeJSON(rw http.ResponseWriter, res *http.Response, rule *JSONRule, doc interface{}) error {
+	b, err := rule.encode(rule.apply(doc), !rule.Minify)
+
+	if err != nil {
+		return err
+	}
+
+	rw.WriteHeader(res.StatusCode)
+	_, err = rw.Write(b)
+
+	return err
+}
+
+// apply removes and redacts the rule's fields in a decoded document.
+func (rule *JSONRule) apply(doc interface{}) interface{} {
+	for _, field := range rule.Remove {
+		doc = visitJSON(doc, strings.Split(field, "."), nil)
+	}
+
+	for _, field := range rule.Redact {
+		doc = visitJSON(doc, strings.Split(field, "."), redacted)
+	}
+
+	return doc
+}
+
+func (rule *JSONRule) encode(doc interface{}, indent bool) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+
+	if indent {
+		enc.SetIndent("", "  ")
+	}
+
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// line applies a rule to one line of a JSON stream, keeping a leading
+// record separator. Lines that don't parse are dropped, as their fields
+// can't be checked.
+func (rule *JSONRule) line(b []byte) ([]byte, bool) {
+	sep := bytes.HasPrefix(b, []byte{recordSeparator})
+	dec := json.NewDecoder(bytes.NewReader(bytes.TrimPrefix(b, []byte{recordSeparator})))
+	dec.UseNumber()
+
+	var doc interface{}
+
+	if err := dec.Decode(&doc); err != nil || decodeEnd(dec) != nil {
+		return nil, false
+	}
+
+	out, err := rule.encode(rule.apply(doc), false)
+
+	if err != nil {
+		return nil, false
+	}
+
+	if sep {
+		out = append([]byte{recordSeparator}, out...)
+	}
+
+	return out, true
+}
+
+// visitJSON removes the value at a path, or replaces it when given a
+// replacement.
+func visitJSON(doc interface{}, path []string, replace interface{}) interface{} {
+	if len(path) == 0 {
+		return doc
+	}
+
+	key, last := path[0], len(path) == 1
+
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		for k, v := range node {
+			if key != "*" && key != k {
+				continue
+			}
+
+			switch {
+			case !last:
+				node[k] = visitJSON(v, path[1:], replace)
+			case replace == nil:
+				delete(node, k)
+			default:
+				node[k] = replace
+			}
+		}
+	case []interface{}:
+		if key != "*" {
+			return doc
+		}
+
+		for i, v := range node {
+			if !last {
+				node[i] = visitJSON(v, path[1:], replace)
+			} else if replace != nil {
+				node[i] = replace
+			}
+		}
+
+		if last && replace == nil {
+			return []interface{}{}
+		}
+	}
+
+	return doc
+}
@@ -0,0 +1,55 @@
+package web
+
+import (
+	"path"
+	"strings"
+
+	"github.com/sats-group/abc/internal/files"
+)
+
+const (
+	// layoutKey holds a layout chosen for a single render in its Env.
+	layoutKey = "_layout"
+
+	// layoutHeader lets the backend choose the layout of a decorated page.
+	layoutHeader = "X-Layout"
+
+	// noLayout renders a page without any layout.
+	noLayout = "none"
+)
+
+// layoutName turns a layout path into a template name.
+func layoutName(layout string) string {
+	if layout == "" {
+		return ""
+	}
+
+	d := strings.TrimPrefix(path.Dir(layout), "/")
+	n := files.Name(layout)
+
+	return path.Join(d, n)
+}
+
+// layout picks the name of the layout template for a render, preferring
+// one chosen in the Env over the configured default. Unknown layouts
+// fall back to it.
+func (e *engine) layout(env interface{}) string {
+	data, ok := env.(Env)
+
+	if !ok {
+		return e.config.layout()
+	}
+
+	l, ok := data[layoutKey].(string)
+
+	switch {
+	case !ok:
+		return e.config.layout()
+	case l == noLayout:
+		return ""
+	case !e.hasTemplate(l):
+		return e.config.layout()
+	}
+
+	return layoutName(l)
+}
@@ -0,0 +1,137 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"html/template"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"sync"
+)
+
+// Content types of backends streaming one JSON value per line.
+var lineTypes = map[string]bool{
+	"application/x-ndjson": true,
+	"application/jsonl":    true,
+	"application/json-seq": true,
+}
+
+// maxLine limits the size of a single streamed JSON line.
+const maxLine = 1 << 20
+
+// linesPlaceholder lets templates parse {{range lines}} before a render
+// binds the stream, yielding nothing outside streamed responses.
+func linesPlaceholder() <-chan interface{} {
+	c := make(chan interface{})
+	close(c)
+	return c
+}
+
+func isLines(res *http.Response) bool {
+	t, _, err := mime.ParseMediaType(res.Header.Get(contentTypeKey))
+	return err == nil && lineTypes[t]
+}
+
+// decorateLines renders a streaming backend response progressively. The
+// template ranges over {{lines}}, receiving each decoded line as it
+// arrives, and {{flush}} sends the output so far to the client.
+func (p *proxy) decorateLines(rw http.ResponseWriter, r *http.Request, res *http.Response, tmpl string) error {
+	defer res.Body.Close()
+
+	if p.engine.missingTemplate(r.Context(), tmpl) {
+		return p.passLines(rw, res, p.config.jsonRule(r.URL.Path))
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	data := Env{responseKey: p.responseEnv(r, res), pageKey: p.pageEnv(res)}
+	funcs := template.FuncMap{"lines": readLines(ctx, res.Body)}
+
+	p.engine.respondFuncs(rw, r, res.StatusCode, tmpl, data, funcs, true)
+
+	return nil
+}
+
+// passLines streams lines through as they arrive, applying a JSON rule
+// to each if given.
+func (p *proxy) passLines(rw http.ResponseWriter, res *http.Response, rule *JSONRule) error {
+	rw.Header().Set(contentTypeKey, res.Header.Get(contentTypeKey))
+	rw.WriteHeader(res.StatusCode)
+
+	f, _ := rw.(http.Flusher)
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(nil, maxLine)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		if rule != nil && len(line) > 0 {
+			var ok bool
+
+			if line, ok = rule.line(line); !ok {
+				log.Println("dropped unparsable JSON line")
+				continue
+			}
+		}
+
+		if _, err := rw.Write(append(line, '\n')); err != nil {
+			return err
+		}
+
+		if f != nil {
+			f.Flush()
+		}
+	}
+
+	return scanner.Err()
+}
+
+// readLines decodes the stream in the background on the first call to
+// {{lines}}. Later calls share the same channel, continuing where the
+// previous range stopped rather than reading the body concurrently.
+func readLines(ctx context.Context, body io.Reader) func() <-chan interface{} {
+	var once sync.Once
+	c := make(chan interface{})
+
+	return func() <-chan interface{} {
+		once.Do(func() {
+			go func() {
+				defer close(c)
+
+				scanner := bufio.NewScanner(body)
+				scanner.Buffer(nil, maxLine)
+
+				for scanner.Scan() {
+					var line interface{}
+					b := bytes.TrimPrefix(scanner.Bytes(), []byte{recordSeparator})
+
+					if len(b) == 0 {
+						continue
+					}
+
+					if err := json.Unmarshal(b, &line); err != nil {
+						log.Println(err)
+						continue
+					}
+
+					select {
+					case c <- line:
+					case <-ctx.Done():
+						return
+					}
+				}
+
+				if err := scanner.Err(); err != nil && ctx.Err() == nil {
+					log.Println(err)
+				}
+			}()
+		})
+
+		return c
+	}
+}
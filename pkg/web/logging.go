@@ -0,0 +1,213 @@
+package web
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/codegangsta/negroni"
+)
+
+// Log levels for LogRules.
+const (
+	LogAll    = "all"
+	LogErrors = "errors"
+	LogOff    = "off"
+)
+
+// A LogRule overrides the access log level and sampling for matching
+// paths. A zero Sample keeps the configured one.
+type LogRule struct {
+	Path   string
+	Level  string
+	Sample int
+}
+
+type timingKey struct{}
+
+// timing splits a request's duration into time spent waiting on
+// upstreams and rendering templates.
+type timing struct {
+	upstream int64
+	render   int64
+}
+
+type accessLog struct {
+	out    *log.Logger
+	sample int
+	slow   time.Duration
+	rules  []LogRule
+	count  uint64
+}
+
+// logOutputs are the writers an instance sends its logs to, nil for
+// the standard logger.
+type logOutputs struct {
+	access io.Writer
+	errors io.Writer
+}
+
+// newLogOutputs opens the configured log files, closing them on
+// shutdown. Access logs go to the error log unless given a writer.
+// Only this instance's logs are redirected: the standard logger, used
+// for everything else, is left to the application.
+func (w *Web) newLogOutputs() {
+	c := w.config
+	w.logs = logOutputs{access: c.AccessLogWriter, errors: c.ErrorLogWriter}
+	opened := []io.Closer{}
+
+	for _, out := range []struct {
+		file   string
+		writer *io.Writer
+	}{{c.AccessLogFile, &w.logs.access}, {c.ErrorLogFile, &w.logs.errors}} {
+		if out.file == "" || *out.writer != nil {
+			continue
+		}
+
+		f, err := NewRotatingFile(out.file, c.LogRotation)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		*out.writer = f
+		opened = append(opened, f)
+	}
+
+	if len(opened) == 0 {
+		return
+	}
+
+	w.OnShutdown(func(context.Context) error {
+		for _, f := range opened {
+			if err := f.Close(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// errorLog is a logger for this instance's errors.
+func (w *Web) errorLog() *log.Logger {
+	if w.logs.errors == nil {
+		return log.Default()
+	}
+
+	return log.New(w.logs.errors, "", log.LstdFlags)
+}
+
+func (w *Web) newLogger() Middleware {
+	if !w.config.AccessLog && w.logs.access == nil {
+		return nil
+	}
+
+	l := &accessLog{
+		out:    w.errorLog(),
+		sample: w.config.LogSample,
+		slow:   w.config.SlowRequest,
+		rules:  w.config.LogRules,
+	}
+
+	if out := w.logs.access; out != nil {
+		l.out = log.New(out, "", log.LstdFlags)
+	}
+
+	return negroni.HandlerFunc(l.ServeHTTP)
+}
+
+func (l *accessLog) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	level, sample := l.rule(r.URL.Path)
+
+	if level == LogOff {
+		next(rw, r)
+		return
+	}
+
+	start := time.Now()
+	t := &timing{}
+	res := NewResponseWriter(rw)
+
+	next(res, r.WithContext(context.WithValue(r.Context(), timingKey{}, t)))
+
+	took := time.Since(start)
+	status := res.Status()
+
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if l.slow > 0 && took >= l.slow {
+		l.out.Printf(
+			"slow: %s %s %d %dB %s (upstream %s, render %s)\n",
+			r.Method, r.URL.RequestURI(), status, res.BytesWritten(), took,
+			time.Duration(atomic.LoadInt64(&t.upstream)),
+			time.Duration(atomic.LoadInt64(&t.render)),
+		)
+
+		return
+	}
+
+	if status < http.StatusBadRequest && (level == LogErrors || !l.sampled(sample)) {
+		return
+	}
+
+	l.out.Printf("%s %s %d %dB %s\n", r.Method, r.URL.RequestURI(), status, res.BytesWritten(), took)
+}
+
+func (l *accessLog) rule(p string) (string, int) {
+	level, sample := LogAll, l.sample
+
+	for _, rule := range l.rules {
+		if matchPath(rule.Path, p) {
+			if rule.Level != "" {
+				level = rule.Level
+			}
+
+			if rule.Sample > 0 {
+				sample = rule.Sample
+			}
+
+			break
+		}
+	}
+
+	return level, sample
+}
+
+// sampled picks one in every n successful requests to log.
+func (l *accessLog) sampled(n int) bool {
+	if n <= 1 {
+		return true
+	}
+
+	return atomic.AddUint64(&l.count, 1)%uint64(n) == 0
+}
+
+// trackUpstream adds time spent on an upstream since start to the
+// request's timing, when it's logged.
+func trackUpstream(ctx context.Context, start time.Time) {
+	if t, ok := ctx.Value(timingKey{}).(*timing); ok {
+		atomic.AddInt64(&t.upstream, int64(time.Since(start)))
+	}
+
+	if t := traceFrom(ctx); t != nil {
+		t.update(func() { t.upstream += time.Since(start) })
+	}
+}
+
+// trackRender adds time spent rendering since start to the request's
+// timing, when it's logged.
+func trackRender(ctx context.Context, start time.Time) {
+	if t, ok := ctx.Value(timingKey{}).(*timing); ok {
+		atomic.AddInt64(&t.render, int64(time.Since(start)))
+	}
+
+	if t := traceFrom(ctx); t != nil {
+		t.update(func() { t.render += time.Since(start) })
+	}
+}
@@ -0,0 +1,26 @@
+package web
+
+import (
+	"path"
+	"strings"
+)
+
+// matchPath reports whether a request path matches a pattern. Patterns
+// ending in "/**" match everything below a prefix, patterns containing
+// other wildcards use path.Match, and plain patterns match the path and
+// everything below it.
+func matchPath(pattern, p string) bool {
+	pattern = "/" + strings.TrimPrefix(pattern, "/")
+
+	if strings.HasSuffix(pattern, "/**") {
+		base := strings.TrimSuffix(pattern, "**")
+		return p == strings.TrimSuffix(base, "/") || strings.HasPrefix(p, base)
+	}
+
+	if strings.ContainsAny(pattern, "*?[") {
+		ok, err := path.Match(pattern, p)
+		return err == nil && ok
+	}
+
+	return p == pattern || strings.HasPrefix(p, strings.TrimSuffix(pattern, "/")+"/")
+}
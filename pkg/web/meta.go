@@ -0,0 +1,100 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/sats-group/abc/pkg/tmpl"
+)
+
+// headKey holds page meta tags declared in decorated JSON.
+const headKey = "_meta"
+
+// metaFields lists the meta values a page may declare.
+var metaFields = []string{"title", "description", "image", "url", "type", "site", "twitter"}
+
+// metaFunc emits title, description, canonical, Open Graph and Twitter
+// tags for a page, as {{meta .}} or with overrides like
+// {{meta . "title" "Home"}}. Values come from the backend's _meta
+// object, then its page metadata, then the overrides.
+func metaFunc(env interface{}, pairs ...interface{}) (template.HTML, error) {
+	overrides, err := tmpl.Dict(pairs...)
+
+	if err != nil {
+		return "", err
+	}
+
+	m := map[string]string{"type": "website"}
+
+	if data, ok := env.(Env); ok {
+		if page, ok := data[pageKey].(Env); ok {
+			setMeta(m, "title", page["title"])
+			setMeta(m, "description", page["description"])
+
+			if links, ok := page["links"].(map[string]string); ok {
+				setMeta(m, "url", links["canonical"])
+			}
+		}
+
+		if head, ok := data[headKey].(map[string]interface{}); ok {
+			for _, key := range metaFields {
+				setMeta(m, key, head[key])
+			}
+		}
+	}
+
+	for _, key := range metaFields {
+		setMeta(m, key, overrides[key])
+	}
+
+	return template.HTML(strings.Join(metaTags(m), "\n")), nil
+}
+
+func setMeta(m map[string]string, key string, val interface{}) {
+	if s, ok := val.(string); ok && s != "" {
+		m[key] = s
+	}
+}
+
+func metaTags(m map[string]string) []string {
+	tags := []string{}
+	esc := template.HTMLEscapeString
+
+	tag := func(attr, name, key string) {
+		if val := m[key]; val != "" {
+			tags = append(tags, fmt.Sprintf("<meta %s=\"%s\" content=\"%s\">", attr, name, esc(val)))
+		}
+	}
+
+	if m["title"] != "" {
+		tags = append(tags, "<title>"+esc(m["title"])+"</title>")
+	}
+
+	tag("name", "description", "description")
+
+	if m["url"] != "" {
+		tags = append(tags, "<link rel=\"canonical\" href=\""+esc(m["url"])+"\">")
+	}
+
+	tag("property", "og:title", "title")
+	tag("property", "og:description", "description")
+	tag("property", "og:image", "image")
+	tag("property", "og:url", "url")
+	tag("property", "og:type", "type")
+	tag("property", "og:site_name", "site")
+
+	m["card"] = "summary"
+
+	if m["image"] != "" {
+		m["card"] = "summary_large_image"
+	}
+
+	tag("name", "twitter:card", "card")
+	tag("name", "twitter:site", "twitter")
+	tag("name", "twitter:title", "title")
+	tag("name", "twitter:description", "description")
+	tag("name", "twitter:image", "image")
+
+	return tags
+}
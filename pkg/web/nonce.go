@@ -0,0 +1,226 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/codegangsta/negroni"
+	"github.com/sats-group/abc/pkg/tmpl"
+)
+
+const defaultCSP = "script-src 'self' 'nonce-{nonce}'; style-src 'self' 'nonce-{nonce}'; object-src 'none'; base-uri 'self'"
+
+type nonceKey struct{}
+
+// WithNonce returns a context whose renders add the nonce to the script
+// and style tags emitted by the asset funcs, for rendering with Execute
+// outside of a request.
+func WithNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, nonceKey{}, nonce)
+}
+
+// Nonce returns the CSP nonce of a request context, if any.
+func Nonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(nonceKey{}).(string)
+	return nonce
+}
+
+func (w *Web) newNonce() Middleware {
+	if !w.config.CSPNonce {
+		return nil
+	}
+
+	policy := w.config.csp()
+
+	fn := func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		b := make([]byte, 16)
+
+		if _, err := rand.Read(b); err != nil {
+			http500(rw, r)
+			return
+		}
+
+		nonce := base64.StdEncoding.EncodeToString(b)
+		rw.Header().Set("Content-Security-Policy", strings.Replace(policy, "{nonce}", nonce, -1))
+		next(rw, r.WithContext(WithNonce(r.Context(), nonce)))
+	}
+
+	return negroni.HandlerFunc(fn)
+}
+
+func (c *Config) csp() string {
+	if c.CSP == "" {
+		return defaultCSP
+	}
+
+	return c.CSP
+}
+
+// nonceFuncs binds the nonce of a render to the funcs emitting inline
+// scripts and styles.
+func (e *engine) nonceFuncs(ctx context.Context, funcs template.FuncMap) {
+	nonce := Nonce(ctx)
+
+	funcs["nonce"] = func() string { return nonce }
+
+	if nonce == "" {
+		return
+	}
+
+	funcs["jsonify"] = func(id string, v interface{}) (template.HTML, error) {
+		return nonced(nonce)(tmpl.Jsonify(id, v))
+	}
+
+	funcs["jsScript"] = func(name string, v interface{}) (template.HTML, error) {
+		return nonced(nonce)(tmpl.JSScript(name, v))
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, name := range []string{paste.name, tpl.name, md.name, css.name, js.name} {
+		if fn, ok := e.funcs[name].(assetFunc); ok {
+			funcs[name] = fn.nonced(nonce)
+		}
+	}
+
+	if fn, ok := e.funcs["font"].(func(string, ...interface{}) (template.HTML, error)); ok {
+		funcs["font"] = func(source string, pairs ...interface{}) (template.HTML, error) {
+			return nonced(nonce)(fn(source, pairs...))
+		}
+	}
+}
+
+func noncePlaceholder() string {
+	return ""
+}
+
+func (fn assetFunc) nonced(nonce string) assetFunc {
+	return func(sources ...interface{}) (template.HTML, error) {
+		return nonced(nonce)(fn(sources...))
+	}
+}
+
+func nonced(nonce string) func(template.HTML, error) (template.HTML, error) {
+	return func(html template.HTML, err error) (template.HTML, error) {
+		if err != nil {
+			return "", err
+		}
+
+		return template.HTML(addNonce(string(html), nonce)), nil
+	}
+}
+
+// nonceMark stands in for the request nonce in cached fragments. It's
+// derived from the cookie secret, so content can't forge it while
+// instances sharing the secret and cache store agree on it.
+func (c *cookies) nonceMark() string {
+	return "nonce-" + hex.EncodeToString(deriveKey(c.sign, "nonce"))
+}
+
+func unnonce(b []byte, nonce string, mark string) []byte {
+	if nonce == "" {
+		return b
+	}
+
+	return bytes.Replace(b, []byte(nonce), []byte(mark), -1)
+}
+
+func renonce(b []byte, nonce string, mark string) string {
+	return strings.Replace(string(b), mark, nonce, -1)
+}
+
+// addNonce adds a nonce attribute to the script and style tags of an
+// HTML snippet that lack one, leaving their contents untouched.
+func addNonce(html string, nonce string) string {
+	lower := lowerASCII(html)
+	out := new(strings.Builder)
+	attr := " nonce=\"" + template.HTMLEscapeString(nonce) + "\""
+
+	for i := 0; i < len(html); {
+		start, tag := nextTag(lower, i)
+
+		if start < 0 {
+			out.WriteString(html[i:])
+			break
+		}
+
+		end := strings.IndexByte(lower[start:], '>')
+
+		if end < 0 {
+			out.WriteString(html[i:])
+			break
+		}
+
+		end += start
+		open := start + len(tag) + 1
+
+		out.WriteString(html[i:open])
+
+		if !strings.Contains(lower[open:end], "nonce=") {
+			out.WriteString(attr)
+		}
+
+		i = open
+		closing := strings.Index(lower[end:], "</"+tag)
+
+		if closing < 0 {
+			out.WriteString(html[i:])
+			break
+		}
+
+		closing += end
+		out.WriteString(html[i:closing])
+		i = closing
+	}
+
+	return out.String()
+}
+
+func nextTag(lower string, from int) (int, string) {
+	start, found := -1, ""
+
+	for _, tag := range []string{"script", "style"} {
+		for i := from; ; {
+			at := strings.Index(lower[i:], "<"+tag)
+
+			if at < 0 {
+				break
+			}
+
+			at += i
+			next := at + len(tag) + 1
+
+			if next < len(lower) && strings.IndexByte(" \t\n\r\f/>", lower[next]) < 0 {
+				i = next
+				continue
+			}
+
+			if start < 0 || at < start {
+				start, found = at, tag
+			}
+
+			break
+		}
+	}
+
+	return start, found
+}
+
+func lowerASCII(s string) string {
+	b := []byte(s)
+
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+
+	return string(b)
+}
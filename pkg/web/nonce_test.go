@@ -0,0 +1,55 @@
+package web
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddNonce(t *testing.T) {
+	tests := map[string]string{
+		`<script src="/a.js"></script>`:              `<script nonce="n" src="/a.js"></script>`,
+		`<STYLE>p{}</STYLE>`:                         `<STYLE nonce="n">p{}</STYLE>`,
+		`<script nonce="old">x()</script>`:           `<script nonce="old">x()</script>`,
+		`<script>"<script>"</script><style></style>`: `<script nonce="n">"<script>"</script><style nonce="n"></style>`,
+		`<scripts></scripts><p>text</p>`:             `<scripts></scripts><p>text</p>`,
+		`<script`:                                    `<script`,
+	}
+
+	for in, want := range tests {
+		if got := addNonce(in, "n"); got != want {
+			t.Errorf("addNonce(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// Cached fragments store a mark in place of the nonce they were
+// rendered with, swapped for the nonce of each request serving them.
+func TestCachedNonce(t *testing.T) {
+	c := &cookies{sign: []byte("secret")}
+	mark := c.nonceMark()
+	html := `<style nonce="first"></style><script nonce="first">x()</script>`
+
+	stored := unnonce([]byte(html), "first", mark)
+
+	if strings.Contains(string(stored), "first") {
+		t.Fatalf("stored fragment keeps the nonce: %s", stored)
+	}
+
+	want := `<style nonce="second"></style><script nonce="second">x()</script>`
+
+	if got := renonce(stored, "second", mark); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	if got := unnonce([]byte(html), "", mark); string(got) != html {
+		t.Errorf("fragment without a nonce changed: %s", got)
+	}
+
+	if mark != (&cookies{sign: []byte("secret")}).nonceMark() {
+		t.Error("instances sharing a secret disagree on the mark")
+	}
+
+	if mark == (&cookies{sign: []byte("other")}).nonceMark() {
+		t.Error("instances with other secrets agree on the mark")
+	}
+}
@@ -0,0 +1,165 @@
+package web
+
+import (
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/codegangsta/negroni"
+	"github.com/sats-group/abc/internal/files"
+)
+
+const (
+	suggestLimit = 3
+	suggestPaths = 1000
+	suggestChars = 200
+)
+
+// A pathIndex keeps the known paths suggested on 404s, listed once in
+// prod so unmatched requests don't walk the site.
+type pathIndex struct {
+	once  sync.Once
+	paths []string
+}
+
+func (w *Web) newNotfound() Middleware {
+	fn := func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		file := w.config.notFound()
+
+		if !w.engine.pick(r.Context()).hasTemplate(file) {
+			http404(rw, r)
+			return
+		}
+
+		env := Env{"path": r.URL.Path}
+
+		if w.config.Suggest {
+			env["suggestions"] = w.suggest(r.URL.Path)
+		}
+
+		w.engine.respond(rw, r, http.StatusNotFound, file, env)
+	}
+
+	return negroni.HandlerFunc(fn)
+}
+
+// suggest lists known pages and routes closest to an unmatched path.
+func (w *Web) suggest(path string) []string {
+	type candidate struct {
+		path string
+		cost int
+	}
+
+	candidates := []candidate{}
+	limit := len(path)/2 + 1
+
+	if len(path) > suggestChars {
+		return nil
+	}
+
+	for _, known := range w.suggestable() {
+		if cost := distance(strings.ToLower(path), known); cost <= limit {
+			candidates = append(candidates, candidate{known, cost})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].cost < candidates[j].cost
+	})
+
+	out := []string{}
+
+	for i := 0; i < len(candidates) && i < suggestLimit; i++ {
+		out = append(out, candidates[i].path)
+	}
+
+	return out
+}
+
+// suggestable lists the known paths, at most suggestPaths of them.
+func (w *Web) suggestable() []string {
+	if !w.config.prod() {
+		return capPaths(w.knownPaths())
+	}
+
+	w.known.once.Do(func() {
+		w.known.paths = capPaths(w.knownPaths())
+	})
+
+	return w.known.paths
+}
+
+func capPaths(paths []string) []string {
+	if len(paths) > suggestPaths {
+		return paths[:suggestPaths]
+	}
+
+	return paths
+}
+
+func (w *Web) knownPaths() []string {
+	known := append([]string{}, w.routes...)
+	ext := w.config.frontendExt()
+	skip := map[string]bool{
+		w.config.notFound():                        true,
+		w.config.layout() + w.config.frontendExt(): true,
+	}
+
+	_ = files.Walk(w.config.dir(), func(rel string) error {
+		rel = filepath.ToSlash(rel)
+
+		if filepath.Ext(rel) != ext || files.Ignore(rel) || skip[rel] {
+			return nil
+		}
+
+		page := "/" + strings.TrimSuffix(rel, ext)
+
+		if strings.HasSuffix(page, "/index") || page == "/index" {
+			page = strings.TrimSuffix(page, "index")
+		}
+
+		known = append(known, page)
+		return nil
+	})
+
+	sort.Strings(known)
+
+	return known
+}
+
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			curr[j] = prev[j-1] + cost
+
+			if prev[j]+1 < curr[j] {
+				curr[j] = prev[j] + 1
+			}
+
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
@@ -0,0 +1,143 @@
+package web
+
+import "time"
+
+// An Option configures a server instance. A *Config is an Option too, so
+// New(c) keeps working; options after it adjust that config.
+type Option interface {
+	apply(c *Config)
+}
+
+type optionFunc func(c *Config)
+
+func (f optionFunc) apply(c *Config) {
+	f(c)
+}
+
+func (c *Config) apply(target *Config) {
+	if c != nil {
+		*target = *c
+	}
+}
+
+// WithConfig changes any config field, for settings without an option.
+func WithConfig(fn func(c *Config)) Option {
+	return optionFunc(fn)
+}
+
+// WithFrontend sets the URL the server listens on.
+func WithFrontend(url string) Option {
+	return optionFunc(func(c *Config) { c.Frontend = url })
+}
+
+// WithBackend sets the URL of the backend to proxy and decorate.
+func WithBackend(url string) Option {
+	return optionFunc(func(c *Config) { c.Backend = url })
+}
+
+// WithBackends sets several backends to balance between.
+func WithBackends(urls ...string) Option {
+	return optionFunc(func(c *Config) { c.Backends = append(c.Backends, urls...) })
+}
+
+// WithRefresh sets how often backends are resolved again.
+func WithRefresh(every time.Duration) Option {
+	return optionFunc(func(c *Config) { c.Refresh = every })
+}
+
+// WithDir sets the site directory.
+func WithDir(dir string) Option {
+	return optionFunc(func(c *Config) { c.Dir = dir })
+}
+
+// WithTemplateDirs adds directories of shared templates, like partials,
+// that are compiled but never served.
+func WithTemplateDirs(dirs ...string) Option {
+	return optionFunc(func(c *Config) { c.TemplateDirs = append(c.TemplateDirs, dirs...) })
+}
+
+// WithLayout sets the default layout template.
+func WithLayout(layout string) Option {
+	return optionFunc(func(c *Config) { c.Layout = layout })
+}
+
+// WithJSON adds JSON data files, exposed to templates as config.
+func WithJSON(rels ...string) Option {
+	return optionFunc(func(c *Config) { c.JSON = append(c.JSON, rels...) })
+}
+
+// WithProxy proxies unmatched requests to the backend.
+func WithProxy() Option {
+	return optionFunc(func(c *Config) { c.Proxy = true })
+}
+
+// WithProd turns on production mode.
+func WithProd() Option {
+	return optionFunc(func(c *Config) { c.Prod = true })
+}
+
+// WithStaging marks a production-like environment that is not indexed.
+func WithStaging() Option {
+	return optionFunc(func(c *Config) { c.Staging = true })
+}
+
+// WithStream streams rendered pages.
+func WithStream() Option {
+	return optionFunc(func(c *Config) { c.Stream = true })
+}
+
+// WithSecret sets the secret used to sign and encrypt cookies.
+func WithSecret(secret string) Option {
+	return optionFunc(func(c *Config) { c.Secret = secret })
+}
+
+// WithAuth adds user:pass@path basic auth patterns.
+func WithAuth(patterns ...string) Option {
+	return optionFunc(func(c *Config) { c.Auth = append(c.Auth, patterns...) })
+}
+
+// WithErrors maps status codes or classes like "5xx" to templates.
+func WithErrors(errors map[string]string) Option {
+	return optionFunc(func(c *Config) { c.Errors = errors })
+}
+
+// WithRouter replaces the default router.
+func WithRouter(router Router) Option {
+	return optionFunc(func(c *Config) { c.Router = router })
+}
+
+// WithCache replaces the default in-memory cache store.
+func WithCache(store CacheStore) Option {
+	return optionFunc(func(c *Config) { c.Cache = store })
+}
+
+// WithPDF sets the converter used for PDF routes.
+func WithPDF(converter PDFConverter) Option {
+	return optionFunc(func(c *Config) { c.PDF = converter })
+}
+
+// WithRenderer replaces html/template with another template syntax.
+func WithRenderer(renderer Renderer) Option {
+	return optionFunc(func(c *Config) { c.Renderer = renderer })
+}
+
+func newConfig(opts []Option) *Config {
+	c := &Config{}
+
+	for i, opt := range opts {
+		// A leading *Config is used as is, as New has always done.
+		if base, ok := opt.(*Config); ok && i == 0 {
+			if base != nil {
+				c = base
+			}
+
+			continue
+		}
+
+		if opt != nil {
+			opt.apply(c)
+		}
+	}
+
+	return c
+}
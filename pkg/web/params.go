@@ -1,6 +1,7 @@
 package web
 
 import (
+	"sort"
 	"strings"
 
 	"github.com/julienschmidt/httprouter"
@@ -11,6 +12,24 @@ type Params struct {
 	httprouter.Params
 }
 
+// NewParams creates params from a map, for use by custom routers.
+func NewParams(values map[string]string) Params {
+	keys := []string{}
+
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	p := Params{}
+
+	for _, key := range keys {
+		p.Params = append(p.Params, httprouter.Param{Key: key, Value: values[key]})
+	}
+
+	return p
+}
+
 // Get returns the value of a named parameter.
 func (p Params) Get(name string) string {
 	return p.Params.ByName(name)
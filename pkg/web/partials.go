@@ -0,0 +1,71 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/sats-group/abc/pkg/tmpl"
+)
+
+// Directories searched for partials, in order.
+var partialDirs = []string{"partials", "_partials"}
+
+// partialFunc creates the {{partial "name" data}} func, rendering a
+// template from a partials directory with its own data. More than one
+// argument is taken as key and value pairs.
+func (e *engine) partialFunc(ctx context.Context, ts Templates, funcs template.FuncMap) func(string, ...interface{}) (template.HTML, error) {
+	return func(name string, args ...interface{}) (template.HTML, error) {
+		var data interface{}
+
+		switch len(args) {
+		case 0:
+		case 1:
+			data = args[0]
+		default:
+			d, err := tmpl.Dict(args...)
+
+			if err != nil {
+				return "", err
+			}
+
+			data = d
+		}
+
+		for _, dir := range partialDirs {
+			p := e.templateName(dir + "/" + name)
+
+			if !ts.Lookup(p) {
+				continue
+			}
+
+			buf := new(bytes.Buffer)
+
+			if err := ts.Execute(&ctxWriter{ctx: ctx, w: buf}, p, data, funcs); err != nil {
+				return "", err
+			}
+
+			return template.HTML(buf.String()), nil
+		}
+
+		return "", fmt.Errorf("unknown partial: %s", name)
+	}
+}
+
+func partialPlaceholder(name string, args ...interface{}) (template.HTML, error) {
+	return "", nil
+}
+
+func isPartial(path string) bool {
+	p := strings.TrimPrefix(path, "/")
+
+	for _, dir := range partialDirs {
+		if strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+
+	return false
+}
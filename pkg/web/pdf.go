@@ -0,0 +1,221 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sats-group/abc/internal/files"
+)
+
+var errNoConverter = errors.New("pdf requires a converter")
+
+// Headers of a rendered page kept on its PDF.
+var pdfHeaders = []string{"Set-Cookie", "Cache-Control", "Expires", "Last-Modified", "Vary"}
+
+var headTag = regexp.MustCompile(`(?i)<head(\s[^>]*)?>`)
+
+// A PDFConverter turns a rendered HTML document into a PDF.
+type PDFConverter interface {
+	Convert(ctx context.Context, html []byte, w io.Writer) error
+}
+
+// ChromeConverter prints PDFs with headless Chrome or Chromium.
+type ChromeConverter struct {
+	Path string
+	Args []string
+}
+
+// WkhtmltopdfConverter prints PDFs with wkhtmltopdf.
+type WkhtmltopdfConverter struct {
+	Path string
+	Args []string
+}
+
+// PDF adds a route rendering a template as a PDF, decorating backend
+// data when there is a backend.
+func (w *Web) PDF(method string, path string, file string) {
+	if w.config.PDF == nil {
+		log.Fatalf("%s: %s\n", errNoConverter, path)
+	}
+
+	tmpl := strings.TrimPrefix(file, "/")
+
+	if filepath.Ext(tmpl) == "" {
+		tmpl += w.config.backendExt()
+	}
+
+	w.HandlerFunc(method, path, func(rw http.ResponseWriter, r *http.Request, _ Params) {
+		buf := &bufferWriter{header: http.Header{}, status: http.StatusOK}
+
+		if w.proxy != nil {
+			w.proxy.serve(buf, r, tmpl, func(rw http.ResponseWriter, r *http.Request) {
+				http404(rw, r)
+			})
+		} else {
+			w.engine.respond(buf, r, http.StatusOK, tmpl, nil)
+		}
+
+		w.engine.pdf(rw, r, buf, files.Name(tmpl)+".pdf")
+	})
+}
+
+// ExecutePDF renders a template file with data as a PDF.
+func (w *Web) ExecutePDF(ctx context.Context, file string, input Env, out io.Writer) error {
+	if w.config.PDF == nil {
+		return errNoConverter
+	}
+
+	buf, err := w.engine.executeContext(ctx, file, input, nil)
+
+	if err != nil {
+		return err
+	}
+
+	return w.config.PDF.Convert(ctx, w.engine.baseHref(buf.Bytes()), out)
+}
+
+func (e *engine) pdf(rw http.ResponseWriter, r *http.Request, buf *bufferWriter, name string) {
+	if buf.status != http.StatusOK {
+		buf.copyTo(rw)
+		return
+	}
+
+	out := new(bytes.Buffer)
+
+	if err := e.config.PDF.Convert(r.Context(), e.baseHref(buf.body.Bytes()), out); err != nil {
+		if r.Context().Err() == nil {
+			log.Println(name, err)
+			http500(rw, r)
+		}
+
+		return
+	}
+
+	for _, key := range pdfHeaders {
+		if vals, ok := buf.header[key]; ok {
+			rw.Header()[key] = vals
+		}
+	}
+
+	rw.Header().Set(contentTypeKey, "application/pdf")
+	rw.Header().Set("Content-Disposition", "inline; filename=\""+name+"\"")
+	rw.WriteHeader(http.StatusOK)
+
+	if _, err := out.WriteTo(rw); err != nil {
+		log.Println(name, err)
+	}
+}
+
+// baseHref resolves the links of a document against the frontend, as
+// converters load it from a file or stdin.
+func (e *engine) baseHref(html []byte) []byte {
+	base := []byte(`<base href="` + template.HTMLEscapeString(e.config.frontend()) + `">`)
+	loc := headTag.FindIndex(html)
+
+	if loc == nil {
+		return append(base, html...)
+	}
+
+	out := append([]byte{}, html[:loc[1]]...)
+	out = append(out, base...)
+
+	return append(out, html[loc[1]:]...)
+}
+
+// Convert prints a document by loading it from a temporary file.
+func (c *ChromeConverter) Convert(ctx context.Context, html []byte, w io.Writer) error {
+	return files.TempDirE("pdf", func(dir string) error {
+		in := path.Join(dir, "page.html")
+		out := path.Join(dir, "page.pdf")
+
+		if err := ioutil.WriteFile(in, html, 0600); err != nil {
+			return err
+		}
+
+		args := append([]string{
+			"--headless",
+			"--disable-gpu",
+			"--no-pdf-header-footer",
+			"--print-to-pdf=" + out,
+		}, c.Args...)
+
+		cmd := exec.CommandContext(ctx, orDefault(c.Path, "chromium"), append(args, "file://"+in)...)
+		cmd.Stderr = os.Stderr
+
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+
+		f, err := os.Open(out)
+
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		_, err = io.Copy(w, f)
+
+		return err
+	})
+}
+
+// Convert prints a document piped through wkhtmltopdf.
+func (c *WkhtmltopdfConverter) Convert(ctx context.Context, html []byte, w io.Writer) error {
+	args := append(append([]string{"--quiet"}, c.Args...), "-", "-")
+	cmd := exec.CommandContext(ctx, orDefault(c.Path, "wkhtmltopdf"), args...)
+	cmd.Stdin = bytes.NewReader(html)
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+func orDefault(val, fallback string) string {
+	if val == "" {
+		return fallback
+	}
+
+	return val
+}
+
+// A bufferWriter holds a response, so it can be converted before it is
+// sent.
+type bufferWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (bw *bufferWriter) Header() http.Header {
+	return bw.header
+}
+
+func (bw *bufferWriter) WriteHeader(status int) {
+	bw.status = status
+}
+
+func (bw *bufferWriter) Write(b []byte) (int, error) {
+	return bw.body.Write(b)
+}
+
+func (bw *bufferWriter) copyTo(rw http.ResponseWriter) {
+	for key, vals := range bw.header {
+		rw.Header()[key] = vals
+	}
+
+	rw.WriteHeader(bw.status)
+	_, _ = bw.body.WriteTo(rw)
+}
@@ -0,0 +1,60 @@
+package web
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/codegangsta/negroni"
+)
+
+// Handlers a RouteRule can send requests to.
+const (
+	RouteEngine = "engine"
+	RouteStatic = "static"
+	RouteProxy  = "proxy"
+)
+
+// A RouteRule sends matching requests straight to one handler, so
+// /api/** can always proxy even where a file of the same name exists.
+// Requests it doesn't handle get the not found response.
+type RouteRule struct {
+	Path string
+	To   string
+}
+
+func (w *Web) newRoutes() Middleware {
+	rules := w.config.Routes
+
+	if len(rules) == 0 {
+		return nil
+	}
+
+	for _, rule := range rules {
+		switch rule.To {
+		case RouteEngine, RouteStatic:
+		case RouteProxy:
+			if w.proxy == nil {
+				log.Fatalf("route requires a backend: %s\n", rule.Path)
+			}
+		default:
+			log.Fatalf("unknown route handler: %s (%s)\n", rule.To, rule.Path)
+		}
+	}
+
+	fn := func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		for _, rule := range rules {
+			if matchPath(rule.Path, r.URL.Path) {
+				w.wares[w.index(rule.To)].mw.ServeHTTP(rw, r, w.notFound)
+				return
+			}
+		}
+
+		next(rw, r)
+	}
+
+	return negroni.HandlerFunc(fn)
+}
+
+func (w *Web) notFound(rw http.ResponseWriter, r *http.Request) {
+	w.wares[w.index(notfoundWare)].mw.ServeHTTP(rw, r, func(http.ResponseWriter, *http.Request) {})
+}
@@ -2,37 +2,167 @@ package web
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"io/ioutil"
+	"errors"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/sats-group/abc/internal/files"
+)
+
+// maxDecoded caps the upstream bodies decoded into memory, as spilled
+// ones may be far larger than the buffer limit.
+const maxDecoded = 32 << 20
+
+var (
+	errTooLarge = errors.New("upstream body too large to decode")
+	errTrailing = errors.New("upstream body has data after its JSON value")
 )
 
 type proxy struct {
-	config *Config
-	engine *engine
-	client *http.Client
+	config    *Config
+	engine    *engine
+	cookies   *cookies
+	client    *http.Client
+	upstreams []*upstream
+	flight    *flight
+
+	requestHooks  []func(*http.Request) error
+	responseHooks []func(*http.Response) error
 }
 
-func (w *Web) newProxy() Middleware {
-	if w.config.backend() == "" {
+func (w *Web) newProxy() *proxy {
+	if w.config.backend() == "" && w.config.Resolver == nil && len(w.config.Upstreams) == 0 {
 		return nil
 	}
 
-	return &proxy{
-		config: w.config,
-		engine: w.engine,
-		client: &http.Client{},
+	p := &proxy{
+		config:    w.config,
+		engine:    w.engine,
+		cookies:   w.cookies,
+		client:    w.config.Signing.client(),
+		upstreams: w.config.upstreams(),
+		flight:    newFlight(),
 	}
+
+	for _, up := range p.upstreams {
+		up.pool.cookies = w.cookies
+	}
+
+	if w.config.Resolver != nil {
+		w.Every(w.config.refresh(), p.discover(w.config.Resolver, w.config.refresh()))
+	}
+
+	return p
+}
+
+func (w *Web) proxyMiddleware() Middleware {
+	if w.proxy == nil {
+		return nil
+	}
+
+	return w.proxy
+}
+
+// Decorate proxies a route to the backend, rendering it with a template
+// instead of the one mirroring the request path.
+func (w *Web) Decorate(method string, path string, file string) {
+	if w.proxy == nil {
+		log.Fatalf("decorate requires a backend: %s\n", path)
+	}
+
+	tmpl := strings.TrimPrefix(file, "/")
+
+	if filepath.Ext(tmpl) == "" {
+		tmpl += w.config.backendExt()
+	}
+
+	w.HandlerFunc(method, path, func(rw http.ResponseWriter, r *http.Request, _ Params) {
+		w.proxy.serve(rw, r, tmpl, func(rw http.ResponseWriter, r *http.Request) {
+			http404(rw, r)
+		})
+	})
 }
 
 func (p *proxy) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-	req, err := p.newRequest(rw, r)
+	p.serve(rw, r, "", next)
+}
+
+func (p *proxy) serve(rw http.ResponseWriter, r *http.Request, tmpl string, next http.HandlerFunc) {
+	up := p.upstream(r.URL.Path)
+
+	if up == nil {
+		next(rw, r)
+		return
+	}
+
+	if !up.bulkhead.enter(r.Context()) {
+		if r.Context().Err() == nil {
+			http503(rw, r)
+		}
+
+		return
+	}
+
+	defer up.bulkhead.leave()
+
+	m := up.pool.pick(rw, r)
+
+	if m == nil {
+		next(rw, r)
+		return
+	}
+
+	m.acquire()
+	defer m.release()
+
+	rule := p.formRule(r)
+	req, err := p.newRequest(m, r)
+	var form url.Values
+
+	if err == nil {
+		up.forward(req, r)
+	}
+
+	if err == nil && rule != nil {
+		form, err = p.forwardForm(req, r, rule)
+	}
+
+	if err == nil {
+		err = up.signing.apply(req)
+	}
+
+	if err == nil {
+		p.identity(req)
+		err = p.beforeRequest(req)
+	}
 
 	fail := func(err error) {
-		log.Fatalln(err)
+		if r.Context().Err() != nil {
+			return
+		}
+
+		var tooLarge *http.MaxBytesError
+
+		if errors.As(err, &tooLarge) {
+			http413(rw, r)
+			return
+		}
+
+		log.Println(err)
+
+		if errors.Is(err, context.DeadlineExceeded) {
+			http504(rw, r)
+			return
+		}
+
 		next(rw, r)
 	}
 
@@ -41,21 +171,52 @@ func (p *proxy) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.Han
 		return
 	}
 
-	res, err := p.proxyPass(req)
+	ctx, cancel := withTimeout(req.Context(), p.config.timeouts(r.URL.Path).upstream)
+	defer cancel()
+
+	start := time.Now()
+	var res *http.Response
+
+	if rule != nil {
+		res, err = noFollow(up.client).Do(req.WithContext(ctx))
+	} else {
+		res, err = p.proxyPass(up, req.WithContext(ctx), r)
+	}
+
+	trackUpstream(r.Context(), start)
+	r = withUpstreamTime(r, time.Since(start))
+	m.observe(r, res, err)
+
+	if err == nil {
+		err = p.afterResponse(res)
+	}
 
 	if err != nil {
 		fail(err)
 		return
 	}
 
-	if err := p.decorate(rw, r, res); err != nil {
+	if rule != nil && res.StatusCode < http.StatusBadRequest {
+		p.redirect(rw, r, res, rule)
+		return
+	}
+
+	if rule != nil && res.StatusCode == http.StatusUnprocessableEntity {
+		if err := p.decorateInvalid(rw, r, res, tmpl, form); err != nil {
+			fail(err)
+		}
+
+		return
+	}
+
+	if err := p.decorate(rw, r, res, tmpl); err != nil {
 		fail(err)
 	}
 }
 
-func (p *proxy) newRequest(rw http.ResponseWriter, r *http.Request) (*http.Request, error) {
-	url := p.config.backend() + strings.TrimPrefix(r.URL.RequestURI(), "/")
-	req, err := http.NewRequest(r.Method, url, r.Body)
+func (p *proxy) newRequest(m *member, r *http.Request) (*http.Request, error) {
+	url := m.base + strings.TrimPrefix(r.URL.RequestURI(), "/")
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, url, r.Body)
 
 	if err != nil {
 		return nil, err
@@ -64,8 +225,15 @@ func (p *proxy) newRequest(rw http.ResponseWriter, r *http.Request) (*http.Reque
 	return req, nil
 }
 
-func (p *proxy) proxyPass(r *http.Request) (*http.Response, error) {
-	res, err := p.client.Do(r)
+// proxyPass sends a backend request made on behalf of the client
+// request r, which tells if it may be coalesced before signing adds
+// credentials of its own.
+func (p *proxy) proxyPass(up *upstream, req *http.Request, r *http.Request) (*http.Response, error) {
+	if p.config.Coalesce && req.Method == http.MethodGet {
+		return p.coalesced(up, req, r)
+	}
+
+	res, err := up.client.Do(req)
 
 	if err != nil {
 		return nil, err
@@ -74,46 +242,212 @@ func (p *proxy) proxyPass(r *http.Request) (*http.Response, error) {
 	return res, nil
 }
 
-func (p *proxy) decorate(rw http.ResponseWriter, r *http.Request, res *http.Response) (e error) {
-	body, err := ioutil.ReadAll(res.Body)
+func (p *proxy) decorate(rw http.ResponseWriter, r *http.Request, res *http.Response, tmpl string) (e error) {
+	if tmpl == "" {
+		tmpl = p.templatePath(r.URL.Path)
+	}
+
+	if bodyless(r, res) {
+		p.cacheHeaders(rw, r, res)
+		rw.WriteHeader(res.StatusCode)
+
+		return res.Body.Close()
+	}
+
+	if err := decompress(res); err != nil {
+		return err
+	}
+
+	transcode(res)
+
+	if isLines(res) {
+		if _, ok := p.config.errorTemplate(res.StatusCode); !ok {
+			return p.decorateLines(rw, r, res, tmpl)
+		}
+	}
+
+	body, err := files.Spool(res.Body, p.config.bufferLimit())
 
 	if err != nil {
 		return err
 	}
 
 	defer func() {
+		if err := body.Close(); err != nil {
+			e = err
+		}
+
 		if err := res.Body.Close(); err != nil {
 			e = err
 		}
 	}()
 
-	path := strings.TrimPrefix(r.URL.Path, "/")
-	base := strings.TrimSuffix(path, filepath.Ext(path))
-	tmpl := base + p.config.backendExt()
 	data := Env{}
 
-	if p.engine.skipFile(tmpl) {
-		return p.decorateJSON(rw, res, body)
+	if errTmpl, ok := p.config.errorTemplate(res.StatusCode); ok {
+		return p.decorateError(rw, r, res, body, errTmpl)
 	}
 
-	if err := json.Unmarshal(body, &data); err != nil {
+	if p.engine.missingTemplate(r.Context(), tmpl) {
+		p.cacheHeaders(rw, r, res)
+		return p.decorateJSON(rw, r, res, body)
+	}
+
+	if err := decodeBody(body, &data, false); err != nil {
 		return err
 	}
 
+	data = p.unwrap(data)
+	data[responseKey] = p.responseEnv(r, res)
+	data[pageKey] = p.pageEnv(res)
+
+	if layout := res.Header.Get(layoutHeader); layout != "" {
+		data[layoutKey] = layout
+	}
+
+	p.cacheHeaders(rw, r, res)
+
 	p.engine.respond(rw, r, res.StatusCode, tmpl, data)
 
 	return nil
 }
 
-func (p *proxy) decorateJSON(rw http.ResponseWriter, res *http.Response, body []byte) error {
+// decodeBody decodes a body holding a single JSON value, rejecting ones
+// too large to hold in memory and anything following the value.
+func decodeBody(body *files.Buffer, v interface{}, numbers bool) error {
+	if body.Len() > maxDecoded {
+		return errTooLarge
+	}
+
+	dec := json.NewDecoder(body.Reader())
+
+	if numbers {
+		dec.UseNumber()
+	}
+
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+
+	return decodeEnd(dec)
+}
+
+// decodeResponse decodes a backend JSON response like decorate does,
+// decompressed, transcoded to UTF-8 and within maxDecoded.
+func (p *proxy) decodeResponse(res *http.Response, v interface{}) error {
+	if err := decompress(res); err != nil {
+		return err
+	}
+
+	transcode(res)
+
+	body, err := files.Spool(io.LimitReader(res.Body, maxDecoded+1), p.config.bufferLimit())
+
+	if err != nil {
+		return err
+	}
+
+	defer body.Close()
+
+	return decodeBody(body, v, false)
+}
+
+func decodeEnd(dec *json.Decoder) error {
+	if _, err := dec.Token(); err != io.EOF {
+		return errTrailing
+	}
+
+	return nil
+}
+
+func (p *proxy) templatePath(path string) string {
+	path = strings.TrimPrefix(path, "/")
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+
+	return base + p.config.backendExt()
+}
+
+func (p *proxy) decorateError(rw http.ResponseWriter, r *http.Request, res *http.Response, body *files.Buffer, tmpl string) error {
+	if tmpl == rawErrors {
+		rw.Header().Set(contentTypeKey, res.Header.Get(contentTypeKey))
+
+		if rule := p.config.jsonRule(r.URL.Path); rule != nil {
+			var doc interface{}
+			err := decodeBody(body, &doc, true)
+
+			if err == errTooLarge {
+				return err
+			}
+
+			if err == nil {
+				return p.writeJSON(rw, res, rule, doc)
+			}
+		}
+
+		rw.WriteHeader(res.StatusCode)
+		_, err := io.Copy(rw, body.Reader())
+
+		return err
+	}
+
+	data := Env{}
+
+	if err := decodeBody(body, &data, false); err == errTooLarge {
+		data = Env{}
+	} else if err != nil {
+		b, err := body.Bytes()
+
+		if err != nil {
+			return err
+		}
+
+		data = Env{"body": string(b)}
+	}
+
+	data["status"] = res.StatusCode
+	data[responseKey] = p.responseEnv(r, res)
+	p.engine.respond(rw, r, res.StatusCode, tmpl, data)
+
+	return nil
+}
+
+// decorateJSON indents JSON in dev when it fits in memory. Otherwise
+// the upstream bytes pass through untouched, unless a JSON rule
+// transforms them.
+func (p *proxy) decorateJSON(rw http.ResponseWriter, r *http.Request, res *http.Response, body *files.Buffer) error {
+	if kind := res.Header.Get(contentTypeKey); kind != "" {
+		rw.Header().Set(contentTypeKey, kind)
+	}
+
+	if rule := p.config.jsonRule(r.URL.Path); rule != nil {
+		return p.transformJSON(rw, res, rule, body)
+	}
+
+	if p.config.prod() || body.Spilled() {
+		if res.ContentLength >= 0 {
+			rw.Header().Set("Content-Length", strconv.FormatInt(res.ContentLength, 10))
+		}
+
+		rw.WriteHeader(res.StatusCode)
+		_, err := io.Copy(rw, body.Reader())
+
+		return err
+	}
+
+	b, err := body.Bytes()
+
+	if err != nil {
+		return err
+	}
+
 	nice := bytes.Buffer{}
 
-	if err := json.Indent(&nice, body, "", "  "); err != nil {
+	if err := json.Indent(&nice, b, "", "  "); err != nil {
 		return err
 	}
 
 	rw.WriteHeader(res.StatusCode)
-	_, err := rw.Write(nice.Bytes())
+	_, err = rw.Write(nice.Bytes())
 
 	return err
 }
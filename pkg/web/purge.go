@@ -0,0 +1,136 @@
+package web
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	pathTagPrefix       = "path:"
+	fragmentIndexPrefix = "fragments:"
+)
+
+// fragmentIndexTTL is the least time a path keeps its index of the
+// fragments served for it, so it outlives most of them.
+const fragmentIndexTTL = 24 * time.Hour
+
+// A purgeRequest lists what a call to the purge endpoint invalidates.
+type purgeRequest struct {
+	Paths []string `json:"paths"`
+	Tags  []string `json:"tags"`
+}
+
+// PurgeCache removes cached fragments served for any of the given
+// request paths, like "/blog/" or "/blog/post", including ones shared
+// with other pages.
+func (w *Web) PurgeCache(paths ...string) {
+	tags := make([]string, len(paths))
+
+	for i, p := range paths {
+		tags[i] = pathTag(p)
+		index := fragmentIndexPrefix + tags[i]
+
+		if b, ok := w.store.Get(index); ok {
+			w.store.Purge(append(fragmentKeys(b), index)...)
+		}
+	}
+
+	w.store.PurgeTag(tags...)
+}
+
+// indexFragment adds a fragment to the index of the request's path, as
+// fragments are only tagged with the path first rendering them. Updates
+// from several instances sharing a store may race, losing entries.
+func (e *engine) indexFragment(ctx context.Context, key string, ttl time.Duration) {
+	r, ok := ctx.Value(requestKey{}).(*http.Request)
+
+	if !ok {
+		return
+	}
+
+	index := fragmentIndexPrefix + pathTag(r.URL.Path)
+	entry := fragmentPrefix + key
+
+	e.indexMu.Lock()
+	defer e.indexMu.Unlock()
+
+	b, _ := e.store.Get(index)
+
+	for _, k := range fragmentKeys(b) {
+		if k == entry {
+			return
+		}
+	}
+
+	if ttl < fragmentIndexTTL {
+		ttl = fragmentIndexTTL
+	}
+
+	e.store.Set(index, append(append([]byte{}, b...), entry+"\n"...), ttl)
+}
+
+func fragmentKeys(index []byte) []string {
+	keys := []string{}
+
+	for _, k := range strings.Split(string(index), "\n") {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys
+}
+
+// newPurge adds the purge endpoint, accepting POSTs of a JSON object
+// with "paths" and "tags" to invalidate, authorized by a bearer token.
+func (w *Web) newPurge() {
+	if w.config.PurgePath == "" {
+		return
+	}
+
+	if w.config.PurgeToken == "" {
+		log.Fatalf("purge endpoint requires a token: %s\n", w.config.PurgePath)
+	}
+
+	token := []byte("Bearer " + w.config.PurgeToken)
+
+	w.HandlerFunc("post", w.config.PurgePath, func(rw http.ResponseWriter, r *http.Request, _ Params) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), token) != 1 {
+			http401(rw, r)
+			return
+		}
+
+		req := &purgeRequest{}
+
+		if err := json.NewDecoder(http.MaxBytesReader(rw, r.Body, 1<<20)).Decode(req); err != nil {
+			http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		w.PurgeCache(req.Paths...)
+		w.PurgeTag(req.Tags...)
+
+		rw.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// pathTag tags entries with a request path, ignoring trailing slashes.
+func pathTag(path string) string {
+	return pathTagPrefix + "/" + strings.Trim(path, "/")
+}
+
+// renderTags adds the path tag of the request a render is for, if any.
+func renderTags(ctx context.Context, tags []string) []string {
+	r, ok := ctx.Value(requestKey{}).(*http.Request)
+
+	if !ok {
+		return tags
+	}
+
+	return append(append([]string{}, tags...), pathTag(r.URL.Path))
+}
@@ -0,0 +1,167 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"io"
+	"path/filepath"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/sats-group/abc/internal/files"
+	"github.com/sats-group/abc/pkg/tmpl"
+)
+
+// A Renderer parses templates in another syntax than html/template, so
+// they can decorate pages and responses with the same proxy, router and
+// asset pipeline. Sources map template names, which are paths without
+// extension like "blog/post", to their files.
+type Renderer interface {
+	Parse(sources map[string]string, funcs template.FuncMap) (Templates, error)
+}
+
+// Templates are parsed by a Renderer and used by concurrent renders.
+// Funcs given to Execute include per-render funcs like partial and yield.
+type Templates interface {
+	Lookup(name string) bool
+	Execute(w io.Writer, name string, data interface{}, funcs template.FuncMap) error
+}
+
+type textRenderer struct{}
+
+// textTemplates execute pooled clones of the parsed templates, as funcs
+// can't be set per render on templates shared by concurrent renders.
+type textTemplates struct {
+	root   *texttemplate.Template
+	funcs  texttemplate.FuncMap
+	clones *sync.Pool
+}
+
+type htmlTemplates struct {
+	t *template.Template
+}
+
+// NewTextRenderer creates a Renderer for text/template, which does not
+// escape its output.
+func NewTextRenderer() Renderer {
+	return textRenderer{}
+}
+
+func (textRenderer) Parse(sources map[string]string, funcs template.FuncMap) (Templates, error) {
+	root := texttemplate.New("").Funcs(texttemplate.FuncMap(funcs))
+
+	for name, file := range sources {
+		data, err := files.ReadE(file)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := root.New(name).Parse(string(data)); err != nil {
+			return nil, err
+		}
+	}
+
+	return textTemplates{root: root, funcs: texttemplate.FuncMap(funcs), clones: &sync.Pool{}}, nil
+}
+
+func (ts textTemplates) Lookup(name string) bool {
+	return ts.root.Lookup(name) != nil
+}
+
+func (ts textTemplates) Execute(w io.Writer, name string, data interface{}, funcs template.FuncMap) error {
+	t, ok := ts.clones.Get().(*texttemplate.Template)
+
+	if !ok {
+		var err error
+
+		if t, err = ts.root.Clone(); err != nil {
+			return err
+		}
+	}
+
+	defer func() {
+		ts.clones.Put(t.Funcs(ts.funcs))
+	}()
+
+	return t.Funcs(texttemplate.FuncMap(funcs)).ExecuteTemplate(w, name, data)
+}
+
+func (ts htmlTemplates) Lookup(name string) bool {
+	return ts.t.Lookup(name) != nil
+}
+
+func (ts htmlTemplates) Execute(w io.Writer, name string, data interface{}, funcs template.FuncMap) error {
+	if len(funcs) > 0 {
+		ts.t.Funcs(funcs)
+	}
+
+	return ts.t.ExecuteTemplate(w, name, data)
+}
+
+func (e *engine) compileRenderer(start time.Time) (*templateSet, error) {
+	sources := map[string]string{}
+
+	err := e.walkTemplates(func(dir string, rel string) error {
+		sources[e.templateName(rel)] = filepath.Join(dir, rel)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	ts, err := e.config.Renderer.Parse(sources, e.funcs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	e.stats.compiled(len(sources), time.Since(start))
+
+	return &templateSet{ext: ts}, nil
+}
+
+// executeRenderer renders with a Renderer's templates, rendering the page
+// first when there is a layout to yield it to.
+func (e *engine) executeRenderer(ctx context.Context, w io.Writer, ts Templates, file string, env interface{}, funcs template.FuncMap) error {
+	scoped := template.FuncMap{}
+
+	e.mu.RLock()
+
+	for k, v := range e.funcs {
+		scoped[k] = v
+	}
+
+	e.mu.RUnlock()
+
+	scoped["cache"] = e.cacheFunc(ctx, ts, scoped)
+	scoped["context"] = func() context.Context { return ctx }
+	scoped["flush"] = flushFunc(w)
+	scoped["partial"] = e.partialFunc(ctx, ts, scoped)
+	scoped["theme"] = e.themeFunc(ctx)
+	scoped["yield"] = tmpl.Yield
+
+	e.nonceFuncs(ctx, scoped)
+
+	for k, v := range funcs {
+		scoped[k] = v
+	}
+
+	name := e.templateName(file)
+
+	if layout := e.layout(env); layout != "" {
+		buf := new(bytes.Buffer)
+
+		if err := ts.Execute(&ctxWriter{ctx: ctx, w: buf}, name, env, scoped); err != nil {
+			return err
+		}
+
+		scoped["yield"] = func() template.HTML { return template.HTML(buf.String()) }
+		name = layout
+	}
+
+	return ts.Execute(w, name, env, scoped)
+}
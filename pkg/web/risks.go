@@ -0,0 +1,165 @@
+package web
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template/parse"
+
+	"github.com/sats-group/abc/internal/files"
+)
+
+// SecurityAudit lists settings and templates weakening the security of
+// a deployment, for ops to review. It is logged by Serve with Audit set
+// and by abc check.
+func (w *Web) SecurityAudit() []string {
+	c := w.config
+	risks := []string{}
+
+	for _, pattern := range c.Auth {
+		if user, _, path, err := splitAuthPattern(pattern); err == nil {
+			risks = append(risks, fmt.Sprintf("auth: plaintext password for %s on /%s", user, path))
+		}
+	}
+
+	if c.Secret == "" {
+		risks = append(risks, "cookies: no Secret, so a random per-process key signs cookies")
+	}
+
+	if c.prod() && !c.Proxy && strings.HasPrefix(c.frontend(), "http://") {
+		risks = append(risks, "frontend: plain HTTP in prod without a TLS-terminating proxy")
+	}
+
+	for _, b := range c.backends() {
+		if u, err := url.Parse(b); err == nil && u.Scheme == "http" && !loopback(u.Hostname()) {
+			risks = append(risks, "backend: unencrypted requests to "+b)
+		}
+	}
+
+	if !c.framed() {
+		risks = append(risks, "headers: FrameDeny is disabled and no X-Frame-Options or frame-ancestors is set for all paths")
+	}
+
+	if !c.Strict || !c.prod() {
+		risks = append(risks, w.engine.unsafeTemplates()...)
+	}
+
+	for _, m := range writeModes() {
+		if m.mode&0002 != 0 {
+			risks = append(risks, fmt.Sprintf("files: %s are world-writable (%s)", m.what, m.mode))
+		}
+	}
+
+	for _, p := range c.writable() {
+		if info, err := os.Stat(p); err == nil && info.Mode().Perm()&0002 != 0 {
+			risks = append(risks, fmt.Sprintf("files: %s is world-writable (%s)", p, info.Mode().Perm()))
+		}
+	}
+
+	return risks
+}
+
+// framed reports if header rules keep all pages from being framed by
+// other sites, as the secure middleware leaves that to them.
+func (c *Config) framed() bool {
+	if strings.Contains(c.CSP, "frame-ancestors") {
+		return true
+	}
+
+	for _, rule := range c.Headers {
+		if !matchPath(rule.Path, "/") {
+			continue
+		}
+
+		for key, val := range rule.Set {
+			if strings.EqualFold(key, "X-Frame-Options") {
+				return true
+			}
+
+			if strings.EqualFold(key, "Content-Security-Policy") && strings.Contains(val, "frame-ancestors") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+type writeMode struct {
+	what string
+	mode os.FileMode
+}
+
+// writeModes lists the modes files.Write and files.MkdirAll give the
+// files and directories written for the cache, builds and exports.
+func writeModes() []writeMode {
+	return []writeMode{
+		{"written files", files.WriteOptions{}.Mode()},
+		{"created directories", files.DirPerm},
+	}
+}
+
+// writable lists the paths templates are loaded from and files are
+// written to.
+func (c *Config) writable() []string {
+	paths := append([]string{c.dir()}, c.TemplateDirs...)
+
+	for _, p := range []string{c.Candidate, c.AssetCache, c.AccessLogFile, c.ErrorLogFile} {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+
+	for _, p := range []string{c.AccessLogFile, c.ErrorLogFile} {
+		if p != "" {
+			paths = append(paths, filepath.Dir(p))
+		}
+	}
+
+	return paths
+}
+
+// unsafeTemplates counts the calls bypassing escaping per template.
+func (e *engine) unsafeTemplates() []string {
+	set, err := e.templateSet()
+
+	if err != nil || set.root == nil {
+		return nil
+	}
+
+	counts := map[string]int{}
+
+	for _, t := range set.root.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+
+		auditNode(t.Tree.Root, func(n *parse.IdentifierNode) {
+			counts[t.Tree.ParseName]++
+		})
+	}
+
+	risks := []string{}
+
+	for name, n := range counts {
+		risks = append(risks, fmt.Sprintf("template %s: %d calls bypass escaping", name, n))
+	}
+
+	sort.Strings(risks)
+
+	return risks
+}
+
+func loopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
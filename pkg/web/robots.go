@@ -0,0 +1,30 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/codegangsta/negroni"
+)
+
+const robotsDeny = "User-agent: *\nDisallow: /\n"
+
+func (w *Web) newRobots() Middleware {
+	if !w.config.noindex() {
+		return nil
+	}
+
+	fn := func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		rw.Header().Set("X-Robots-Tag", "noindex, nofollow")
+
+		if r.URL.Path != "/robots.txt" {
+			next(rw, r)
+			return
+		}
+
+		rw.Header().Set(contentTypeKey, "text/plain; charset=utf-8")
+		rw.WriteHeader(http.StatusOK)
+		_, _ = rw.Write([]byte(robotsDeny))
+	}
+
+	return negroni.HandlerFunc(fn)
+}
@@ -0,0 +1,155 @@
+package web
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Rotation sets when a log file is rotated: once it would grow past
+// MaxSize bytes or has been written to for MaxAge. Backups limits the
+// rotated files kept, keeping all of them if zero.
+type Rotation struct {
+	MaxSize int64
+	MaxAge  time.Duration
+	Backups int
+}
+
+// backupStamp suffixes rotated files, sorting them by age.
+const backupStamp = "20060102-150405.000"
+
+type rotatingFile struct {
+	mu     sync.Mutex
+	path   string
+	r      Rotation
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+// NewRotatingFile opens a log file for appending, renaming it with a
+// timestamp suffix and starting a new one as set by the Rotation. Any
+// io.Writer may be used for logs instead, like a lumberjack.Logger.
+func NewRotatingFile(path string, r Rotation) (io.WriteCloser, error) {
+	f := &rotatingFile{path: path, r: r}
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.due(len(p)) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.f.Write(p)
+	f.size += int64(n)
+
+	return n, err
+}
+
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.f.Close()
+}
+
+func (f *rotatingFile) due(n int) bool {
+	if f.size == 0 {
+		return false
+	}
+
+	if f.r.MaxSize > 0 && f.size+int64(n) > f.r.MaxSize {
+		return true
+	}
+
+	return f.r.MaxAge > 0 && time.Since(f.opened) >= f.r.MaxAge
+}
+
+func (f *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	f.f, f.size, f.opened = file, info.Size(), time.Now()
+
+	return nil
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.f.Close(); err != nil {
+		return err
+	}
+
+	backup := f.path + "." + time.Now().Format(backupStamp)
+
+	if err := os.Rename(f.path, backup); err != nil {
+		return err
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.prune()
+
+	return nil
+}
+
+// prune removes the oldest rotated files beyond the backups kept,
+// leaving files whose suffix isn't a backup timestamp alone.
+func (f *rotatingFile) prune() {
+	if f.r.Backups <= 0 {
+		return
+	}
+
+	matches, err := filepath.Glob(f.path + ".*")
+
+	if err != nil {
+		return
+	}
+
+	backups := []string{}
+
+	for _, m := range matches {
+		if _, err := time.Parse(backupStamp, strings.TrimPrefix(m, f.path+".")); err == nil {
+			backups = append(backups, m)
+		}
+	}
+
+	if len(backups) <= f.r.Backups {
+		return
+	}
+
+	sort.Strings(backups)
+
+	for _, old := range backups[:len(backups)-f.r.Backups] {
+		_ = os.Remove(old)
+	}
+}
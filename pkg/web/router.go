@@ -7,36 +7,41 @@ import (
 	"github.com/julienschmidt/httprouter"
 )
 
+// A Router matches requests to handlers by method and path. Requests
+// without a match must be passed on to the next middleware.
+type Router interface {
+	Middleware
+	Handle(method string, path string, handler Handler)
+}
+
 type router struct {
 	*httprouter.Router
 }
 
-func (w *Web) newRouter() *router {
+// NewRouter creates the default router, backed by httprouter.
+func NewRouter() Router {
 	return &router{
 		httprouter.New(),
 	}
 }
 
+func (w *Web) newRouter() Router {
+	if w.config.Router != nil {
+		return w.config.Router
+	}
+
+	return NewRouter()
+}
+
 func (rt *router) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	rt.Router.HandleMethodNotAllowed = false
 	rt.Router.NotFound = next
 	rt.Router.ServeHTTP(rw, r)
 }
 
-func (rt *router) handler(method string, path string, handler Handler) {
-	rt.handle(path, method, func(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+func (rt *router) Handle(method string, path string, handler Handler) {
+	path = strings.ToLower(path)
+	rt.Router.Handle(method, path, func(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		handler.ServeHTTP(rw, r, Params{p})
 	})
 }
-
-func (rt *router) handlerFunc(method string, path string, handler HandlerFunc) {
-	rt.handle(path, method, func(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
-		handler(rw, r, Params{p})
-	})
-}
-
-func (rt *router) handle(path string, method string, fn httprouter.Handle) {
-	path = strings.ToLower(path)
-	method = strings.ToUpper(method)
-	rt.Router.Handle(method, path, fn)
-}
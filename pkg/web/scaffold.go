@@ -0,0 +1,82 @@
+package web
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/sats-group/abc/internal/files"
+)
+
+// starter is the site written by Scaffold, keyed by relative path.
+var starter = map[string]string{
+	"site.json": `{
+  "title": "New site"
+}
+`,
+	"layout.html": `<!doctype html>
+<html>
+  <head>
+    <meta charset="utf-8">
+    <title>{{.config.site.title}}</title>
+    {{css "css"}}
+  </head>
+  <body>
+    {{yield}}
+    {{js "js"}}
+  </body>
+</html>
+`,
+	"index.html": `<h1>{{.config.site.title}}</h1>
+<p>Edit index.html to get started.</p>
+`,
+	"404.html": `<h1>Not found</h1>
+`,
+	"partials/nav.html": `<nav><a href="/">Home</a></nav>
+`,
+	"api/items.tmpl": `{{/* Decorates JSON from GET /api/items on the backend, run with a backend and proxying on. */}}
+<ul>
+  {{range .items}}<li>{{.name}}</li>{{end}}
+</ul>
+`,
+	"css/main.css": `body {
+  font-family: sans-serif;
+}
+`,
+	"js/main.js": `// Scripts in js/ are bundled in order of their numbered prefix.
+`,
+	files.IgnoreFile: `# Files matching these patterns are never served, rendered or bundled.
+*.md
+`,
+}
+
+// Scaffold writes a starter site to dir, with a layout, pages, assets,
+// site.json data and an example decoration template. It never overwrites
+// existing files.
+func Scaffold(dir string) error {
+	rels := make([]string, 0, len(starter))
+
+	for rel := range starter {
+		if target := filepath.Join(dir, filepath.FromSlash(rel)); files.HasFile(target) {
+			return fmt.Errorf("file exists: %s", target)
+		}
+
+		rels = append(rels, rel)
+	}
+
+	sort.Strings(rels)
+
+	for _, rel := range rels {
+		target := filepath.Join(dir, filepath.FromSlash(rel))
+
+		if err := files.MkdirAll(filepath.Dir(target)); err != nil {
+			return err
+		}
+
+		if err := files.Write(target, []byte(starter[rel])); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
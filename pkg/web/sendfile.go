@@ -0,0 +1,118 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SendOptions configures a file download.
+type SendOptions struct {
+	// Filename is offered to save the file as, defaulting to its name.
+	Filename string
+
+	// Inline lets browsers show the file instead of downloading it.
+	Inline bool
+
+	// Rate limits the transfer in bytes per second, if above zero.
+	Rate int64
+}
+
+// A rateReader throttles reads of a file to a number of bytes per
+// second, until its context is done.
+type rateReader struct {
+	ctx   context.Context
+	file  io.ReadSeeker
+	rate  int64
+	start time.Time
+	read  int64
+}
+
+// SendFile responds with a file from disk, with range requests for
+// resuming downloads, conditional requests and a Content-Disposition
+// header.
+func (w *Web) SendFile(rw http.ResponseWriter, r *http.Request, file string, opts SendOptions) error {
+	f, err := os.Open(file)
+
+	if err != nil {
+		http404(rw, r)
+		return err
+	}
+
+	defer f.Close()
+
+	info, err := f.Stat()
+
+	if err == nil && info.IsDir() {
+		err = fmt.Errorf("send file: %s is a directory", file)
+	}
+
+	if err != nil {
+		http404(rw, r)
+		return err
+	}
+
+	name := opts.Filename
+
+	if name == "" {
+		name = filepath.Base(file)
+	}
+
+	disposition := "attachment"
+
+	if opts.Inline {
+		disposition = "inline"
+	}
+
+	rw.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": name}))
+
+	var content io.ReadSeeker = f
+
+	if opts.Rate > 0 {
+		content = &rateReader{ctx: r.Context(), file: f, rate: opts.Rate}
+	}
+
+	http.ServeContent(rw, r, name, info.ModTime(), content)
+
+	return nil
+}
+
+func (rr *rateReader) Read(p []byte) (int, error) {
+	if rr.start.IsZero() {
+		rr.start = time.Now()
+	}
+
+	if int64(len(p)) > rr.rate {
+		p = p[:rr.rate]
+	}
+
+	// Float math, as bytes read times a second in nanoseconds overflows
+	// past about 9GB.
+	due := time.Duration(float64(rr.read) / float64(rr.rate) * float64(time.Second))
+	ahead := due - time.Since(rr.start)
+
+	if ahead > 0 {
+		timer := time.NewTimer(ahead)
+		defer timer.Stop()
+
+		select {
+		case <-rr.ctx.Done():
+			return 0, rr.ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	n, err := rr.file.Read(p)
+	rr.read += int64(n)
+
+	return n, err
+}
+
+func (rr *rateReader) Seek(offset int64, whence int) (int64, error) {
+	return rr.file.Seek(offset, whence)
+}
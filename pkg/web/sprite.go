@@ -0,0 +1,147 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"log"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sats-group/abc/internal/files"
+	"github.com/sats-group/abc/pkg/tmpl"
+)
+
+const spritePrefix = "icon-"
+
+// sprite builds a sheet of symbols from every icon in the icons dir and
+// keeps it in the asset cache, so it's served from the hashed asset root.
+// It's built at startup, and again on each use outside prod.
+func (s *svgIcons) sprite() (*assetCache, error) {
+	s.mu.Lock()
+	sheet := s.sheet
+	s.mu.Unlock()
+
+	if sheet != nil && s.prod {
+		return sheet, nil
+	}
+
+	names := []string{}
+
+	err := files.Walk(s.dir, func(rel string) error {
+		rel = filepath.ToSlash(rel)
+
+		if path.Ext(rel) == ".svg" && !files.Excluded(rel) {
+			names = append(names, strings.TrimSuffix(rel, ".svg"))
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+
+	buf := bytes.NewBufferString(`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="` + xlinkSpace + `">`)
+	paths := []string{}
+
+	for _, name := range names {
+		icon, err := s.load(name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		buf.WriteString(icon.symbol(symbolID(name)))
+		paths = append(paths, path.Join(s.rel, name+".svg"))
+	}
+
+	buf.WriteString("</svg>")
+
+	sheet = &assetCache{
+		name:  hash(buf.String()) + ".svg",
+		mime:  "image/svg+xml",
+		time:  time.Now(),
+		paths: paths,
+		bytes: buf.Bytes(),
+	}
+
+	s.assets.put(sheet)
+
+	s.mu.Lock()
+	s.sheet = sheet
+	s.mu.Unlock()
+
+	return sheet, nil
+}
+
+// spriteFunc emits an svg using a symbol from the sprite sheet. Pairs
+// set attributes like for icon.
+func (s *svgIcons) spriteFunc(name string, pairs ...interface{}) (template.HTML, error) {
+	attrs, err := tmpl.Dict(pairs...)
+
+	if err != nil {
+		return "", err
+	}
+
+	sheet, err := s.sprite()
+
+	if err != nil {
+		return "", err
+	}
+
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	href := path.Join(s.assets.root, s.assets.prefix, sheet.name) + "#" + symbolID(name)
+	use := &svgIcon{inner: fmt.Sprintf(`<use href="%s"></use>`, template.HTMLEscapeString(href))}
+
+	return template.HTML(use.render(attrs)), nil
+}
+
+func (s *svgIcons) buildSprite() {
+	if !files.HasDir(s.dir) {
+		return
+	}
+
+	if _, err := s.sprite(); err != nil {
+		log.Fatalf("sprite error: %s\n", err)
+	}
+}
+
+func (i *svgIcon) symbol(id string) string {
+	buf := bytes.NewBufferString(`<symbol id="` + id + `"`)
+	viewBox, width, height := "", "", ""
+
+	for _, attr := range i.attrs {
+		switch attr.Name.Local {
+		case "viewBox":
+			viewBox = attr.Value
+		case "width":
+			width = attr.Value
+		case "height":
+			height = attr.Value
+		}
+	}
+
+	if viewBox == "" && width != "" && height != "" {
+		viewBox = "0 0 " + width + " " + height
+	}
+
+	if viewBox != "" {
+		writeAttr(buf, "viewBox", viewBox)
+	}
+
+	buf.WriteString(">")
+	buf.WriteString(i.inner)
+	buf.WriteString("</symbol>")
+
+	return buf.String()
+}
+
+func symbolID(name string) string {
+	return spritePrefix + strings.Replace(name, "/", "-", -1)
+}
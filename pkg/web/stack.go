@@ -0,0 +1,92 @@
+package web
+
+import "fmt"
+
+const (
+	routerWare   = "router"
+	notfoundWare = "notfound"
+	customWare   = "middleware"
+)
+
+// A ware is a named middleware in the stack. Wares that are turned off by
+// the config keep their place but are skipped.
+type ware struct {
+	name string
+	mw   Middleware
+}
+
+// A Named middleware is listed by its name in the stack, so more
+// middleware can be inserted around it.
+type Named interface {
+	Name() string
+}
+
+// Stack lists the names of the middleware in the stack, in order.
+func (w *Web) Stack() []string {
+	names := []string{}
+
+	for _, ware := range w.wares {
+		if ware.mw != nil {
+			names = append(names, ware.name)
+		}
+	}
+
+	return names
+}
+
+// MiddlewareBefore adds ware objects to the stack before a named one,
+// such as "proxy" or "engine".
+func (w *Web) MiddlewareBefore(name string, mw ...Middleware) error {
+	i := w.index(name)
+
+	if i == -1 {
+		return fmt.Errorf("unknown middleware: %s", name)
+	}
+
+	w.insert(i, mw)
+
+	return nil
+}
+
+// MiddlewareAfter adds ware objects to the stack after a named one, such
+// as "static" or "auth".
+func (w *Web) MiddlewareAfter(name string, mw ...Middleware) error {
+	i := w.index(name)
+
+	if i == -1 {
+		return fmt.Errorf("unknown middleware: %s", name)
+	}
+
+	w.insert(i+1, mw)
+
+	return nil
+}
+
+func (w *Web) index(name string) int {
+	for i, ware := range w.wares {
+		if ware.name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (w *Web) insert(i int, mw []Middleware) {
+	wares := make([]ware, 0, len(w.wares)+len(mw))
+	wares = append(wares, w.wares[:i]...)
+
+	for _, m := range mw {
+		wares = append(wares, ware{wareName(m), m})
+	}
+
+	w.wares = append(wares, w.wares[i:]...)
+}
+
+func wareName(mw Middleware) string {
+	if n, ok := mw.(Named); ok {
+		return n.Name()
+	}
+
+	return customWare
+}
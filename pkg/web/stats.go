@@ -0,0 +1,126 @@
+package web
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Stats summarizes template and asset caching, to help tune prod
+// deployments.
+type Stats struct {
+	Templates      int64
+	Compiles       int64
+	CompileTime    time.Duration
+	Assets         int64
+	AssetBytes     int64
+	AssetHits      int64
+	AssetMisses    int64
+	BundleTime     time.Duration
+	FragmentHits   int64
+	FragmentMisses int64
+}
+
+// counters are updated atomically as templates compile and caches are
+// used.
+type counters struct {
+	templates      int64
+	compiles       int64
+	compileNanos   int64
+	assets         int64
+	assetBytes     int64
+	assetHits      int64
+	assetMisses    int64
+	bundleNanos    int64
+	fragmentHits   int64
+	fragmentMisses int64
+}
+
+func (w *Web) newStats() *counters {
+	c := &counters{}
+
+	if every := w.config.StatsInterval; every > 0 {
+		w.Every(every, func(context.Context) error {
+			c.snapshot().log()
+			return nil
+		})
+	}
+
+	return c
+}
+
+// Stats returns current template and asset cache statistics.
+func (w *Web) Stats() Stats {
+	return w.stats.snapshot()
+}
+
+// LogStats writes current template and asset cache statistics to the log.
+func (w *Web) LogStats() {
+	w.stats.snapshot().log()
+}
+
+func (c *counters) compiled(templates int, took time.Duration) {
+	atomic.StoreInt64(&c.templates, int64(templates))
+	atomic.AddInt64(&c.compiles, 1)
+	atomic.AddInt64(&c.compileNanos, int64(took))
+}
+
+func (c *counters) asset(hit bool) {
+	if hit {
+		atomic.AddInt64(&c.assetHits, 1)
+	} else {
+		atomic.AddInt64(&c.assetMisses, 1)
+	}
+}
+
+func (c *counters) bundled(old *assetCache, file *assetCache, took time.Duration) {
+	if old != nil {
+		atomic.AddInt64(&c.assets, -1)
+		atomic.AddInt64(&c.assetBytes, -int64(len(old.bytes)))
+	}
+
+	atomic.AddInt64(&c.assets, 1)
+	atomic.AddInt64(&c.assetBytes, int64(len(file.bytes)))
+	atomic.AddInt64(&c.bundleNanos, int64(took))
+}
+
+func (c *counters) fragment(hit bool) {
+	if hit {
+		atomic.AddInt64(&c.fragmentHits, 1)
+	} else {
+		atomic.AddInt64(&c.fragmentMisses, 1)
+	}
+}
+
+func (c *counters) snapshot() Stats {
+	return Stats{
+		Templates:      atomic.LoadInt64(&c.templates),
+		Compiles:       atomic.LoadInt64(&c.compiles),
+		CompileTime:    time.Duration(atomic.LoadInt64(&c.compileNanos)),
+		Assets:         atomic.LoadInt64(&c.assets),
+		AssetBytes:     atomic.LoadInt64(&c.assetBytes),
+		AssetHits:      atomic.LoadInt64(&c.assetHits),
+		AssetMisses:    atomic.LoadInt64(&c.assetMisses),
+		BundleTime:     time.Duration(atomic.LoadInt64(&c.bundleNanos)),
+		FragmentHits:   atomic.LoadInt64(&c.fragmentHits),
+		FragmentMisses: atomic.LoadInt64(&c.fragmentMisses),
+	}
+}
+
+func (s Stats) log() {
+	log.Printf(
+		"stats: templates=%d compiles=%d compile_time=%s assets=%d asset_bytes=%d asset_hit_ratio=%.2f bundle_time=%s fragment_hit_ratio=%.2f\n",
+		s.Templates, s.Compiles, s.CompileTime, s.Assets, s.AssetBytes,
+		ratio(s.AssetHits, s.AssetMisses), s.BundleTime,
+		ratio(s.FragmentHits, s.FragmentMisses),
+	)
+}
+
+func ratio(hits, misses int64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+
+	return float64(hits) / float64(hits+misses)
+}
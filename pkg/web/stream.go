@@ -0,0 +1,81 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+)
+
+var headEnd = []byte("</head>")
+
+// A flushWriter sends rendered output to the client as soon as the
+// document head is complete and at each {{flush}} in a template.
+type flushWriter struct {
+	ctx context.Context
+	w   io.Writer
+	f   http.Flusher
+}
+
+// A ctxWriter stops template execution once its context is done.
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (e *engine) stream(rw http.ResponseWriter, f http.Flusher, r *http.Request, status int, file string, env Env, funcs template.FuncMap) {
+	if layout := e.layout(env); !e.hasTemplate(file) || (layout != "" && !e.hasName(layout)) {
+		log.Println(file, "template not found")
+		http404(rw, r)
+		return
+	}
+
+	rw.Header().Set(contentTypeKey, contentTypeVal)
+	rw.WriteHeader(status)
+
+	fw := &flushWriter{ctx: r.Context(), w: rw, f: f}
+
+	if err := e.executeTo(r.Context(), fw, file, env, funcs); err != nil && r.Context().Err() == nil {
+		log.Println(file, err)
+	}
+
+	fw.flush()
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	if err := fw.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := fw.w.Write(p)
+
+	if err == nil && bytes.Contains(p, headEnd) {
+		fw.flush()
+	}
+
+	return n, err
+}
+
+func (fw *flushWriter) flush() {
+	fw.f.Flush()
+}
+
+func (cw *ctxWriter) Write(p []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return cw.w.Write(p)
+}
+
+func flushFunc(w io.Writer) func() string {
+	return func() string {
+		if fw, ok := w.(*flushWriter); ok {
+			fw.flush()
+		}
+
+		return ""
+	}
+}
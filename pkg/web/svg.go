@@ -0,0 +1,268 @@
+package web
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sats-group/abc/pkg/tmpl"
+)
+
+const (
+	xlinkSpace = "http://www.w3.org/1999/xlink"
+	xmlSpace   = "http://www.w3.org/XML/1998/namespace"
+)
+
+// SVG elements kept when inlining icons. Anything else, like scripts and
+// foreign objects, is dropped with its content.
+var svgElements = map[string]bool{
+	"svg": true, "g": true, "defs": true, "symbol": true, "use": true,
+	"title": true, "desc": true, "path": true, "circle": true,
+	"ellipse": true, "line": true, "polyline": true, "polygon": true,
+	"rect": true, "text": true, "tspan": true, "clipPath": true,
+	"mask": true, "linearGradient": true, "radialGradient": true,
+	"stop": true,
+}
+
+type svgIcons struct {
+	dir    string
+	rel    string
+	prod   bool
+	assets *assets
+
+	mu    sync.Mutex
+	cache map[string]*svgIcon
+	sheet *assetCache
+}
+
+// An svgIcon is a sanitized SVG, split so attributes can be set on its
+// root element.
+type svgIcon struct {
+	attrs []xml.Attr
+	inner string
+}
+
+func (w *Web) newSVGs() *svgIcons {
+	s := &svgIcons{
+		dir:    filepath.Join(w.config.dir(), w.config.iconDir()),
+		rel:    w.config.iconDir(),
+		prod:   w.config.prod(),
+		assets: w.assets,
+		cache:  map[string]*svgIcon{},
+	}
+
+	s.buildSprite()
+
+	w.FuncMap(template.FuncMap{
+		"icon":   s.icon,
+		"sprite": s.spriteFunc,
+	})
+
+	return s
+}
+
+// icon inlines a sanitized SVG from the icons dir. Pairs set attributes
+// on it, where "size" sets both width and height.
+func (s *svgIcons) icon(name string, pairs ...interface{}) (template.HTML, error) {
+	attrs, err := tmpl.Dict(pairs...)
+
+	if err != nil {
+		return "", err
+	}
+
+	icon, err := s.load(name)
+
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(icon.render(attrs)), nil
+}
+
+func (s *svgIcons) load(name string) (*svgIcon, error) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if icon, ok := s.cache[name]; ok && s.prod {
+		return icon, nil
+	}
+
+	file, err := os.Open(filepath.Join(s.dir, filepath.FromSlash(name)+".svg"))
+
+	if err != nil {
+		return nil, fmt.Errorf("unknown icon: %s", name)
+	}
+
+	defer file.Close()
+
+	icon, err := parseSVG(file)
+
+	if err != nil {
+		return nil, fmt.Errorf("icon error: %s (%s)", name, err)
+	}
+
+	s.cache[name] = icon
+
+	return icon, nil
+}
+
+func (i *svgIcon) render(attrs map[string]interface{}) string {
+	set := map[string]string{}
+
+	for key, val := range attrs {
+		if key == "size" {
+			set["width"] = fmt.Sprint(val)
+			set["height"] = fmt.Sprint(val)
+		} else {
+			set[key] = fmt.Sprint(val)
+		}
+	}
+
+	buf := bytes.NewBufferString("<svg")
+
+	for _, attr := range i.attrs {
+		name := attrName(attr.Name)
+
+		if _, ok := set[name]; !ok {
+			writeAttr(buf, name, attr.Value)
+		}
+	}
+
+	keys := make([]string, 0, len(set))
+
+	for key := range set {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		writeAttr(buf, key, set[key])
+	}
+
+	buf.WriteString(">")
+	buf.WriteString(i.inner)
+	buf.WriteString("</svg>")
+
+	return buf.String()
+}
+
+// parseSVG reads an SVG, keeping only known elements and dropping event
+// handlers and links to anything but fragments in the same document.
+func parseSVG(r io.Reader) (*svgIcon, error) {
+	dec := xml.NewDecoder(r)
+	icon := &svgIcon{}
+	buf := &bytes.Buffer{}
+	depth, skip := 0, 0
+
+	for {
+		tok, err := dec.Token()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+
+			if skip > 0 || !svgElements[t.Name.Local] {
+				skip++
+				continue
+			}
+
+			attrs := safeAttrs(t.Attr)
+
+			if depth == 1 {
+				if t.Name.Local != "svg" {
+					return nil, fmt.Errorf("not an svg: %s", t.Name.Local)
+				}
+
+				icon.attrs = attrs
+				continue
+			}
+
+			buf.WriteString("<" + t.Name.Local)
+
+			for _, attr := range attrs {
+				writeAttr(buf, attrName(attr.Name), attr.Value)
+			}
+
+			buf.WriteString(">")
+		case xml.EndElement:
+			depth--
+
+			if skip > 0 {
+				skip--
+				continue
+			}
+
+			if depth > 0 {
+				buf.WriteString("</" + t.Name.Local + ">")
+			}
+		case xml.CharData:
+			if skip == 0 && depth > 1 {
+				buf.WriteString(html.EscapeString(string(t)))
+			}
+		}
+	}
+
+	if icon.attrs == nil {
+		return nil, fmt.Errorf("no svg element")
+	}
+
+	icon.inner = buf.String()
+
+	return icon, nil
+}
+
+func safeAttrs(attrs []xml.Attr) []xml.Attr {
+	safe := []xml.Attr{}
+
+	for _, attr := range attrs {
+		name := strings.ToLower(attr.Name.Local)
+
+		switch {
+		case attr.Name.Space != "" && attr.Name.Space != xlinkSpace && attr.Name.Space != xmlSpace && attr.Name.Space != "xmlns":
+		case strings.HasPrefix(name, "on"):
+		case name == "href" && !strings.HasPrefix(attr.Value, "#"):
+		case strings.Contains(strings.ToLower(attr.Value), "javascript:"):
+		default:
+			safe = append(safe, attr)
+		}
+	}
+
+	return safe
+}
+
+func attrName(name xml.Name) string {
+	switch name.Space {
+	case "":
+		return name.Local
+	case xlinkSpace:
+		return "xlink:" + name.Local
+	case xmlSpace:
+		return "xml:" + name.Local
+	}
+
+	return name.Space + ":" + name.Local
+}
+
+func writeAttr(buf *bytes.Buffer, name string, val string) {
+	fmt.Fprintf(buf, " %s=\"%s\"", name, html.EscapeString(val))
+}
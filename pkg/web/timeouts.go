@@ -0,0 +1,89 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/codegangsta/negroni"
+)
+
+// A TimeoutRule overrides timeouts for matching paths. Zero durations
+// keep the configured ones.
+type TimeoutRule struct {
+	Path     string
+	Upstream time.Duration
+	Render   time.Duration
+	Request  time.Duration
+}
+
+type timeouts struct {
+	upstream time.Duration
+	render   time.Duration
+	request  time.Duration
+}
+
+// timeouts picks the timeouts for a path, from the first matching rule
+// over the global ones.
+func (c *Config) timeouts(p string) timeouts {
+	t := timeouts{c.UpstreamTimeout, c.RenderTimeout, c.RequestTimeout}
+
+	for _, rule := range c.Timeouts {
+		if !matchPath(rule.Path, p) {
+			continue
+		}
+
+		if rule.Upstream > 0 {
+			t.upstream = rule.Upstream
+		}
+
+		if rule.Render > 0 {
+			t.render = rule.Render
+		}
+
+		if rule.Request > 0 {
+			t.request = rule.Request
+		}
+
+		break
+	}
+
+	return t
+}
+
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, d)
+}
+
+// newDeadline bounds the whole request, answering 503 when time runs
+// out before anything was written.
+func (w *Web) newDeadline() Middleware {
+	if w.config.RequestTimeout <= 0 && len(w.config.Timeouts) == 0 {
+		return nil
+	}
+
+	fn := func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		d := w.config.timeouts(r.URL.Path).request
+
+		if d <= 0 {
+			next(rw, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+
+		res := NewResponseWriter(rw)
+		next(res, r.WithContext(ctx))
+
+		if ctx.Err() == context.DeadlineExceeded && res.Status() == 0 {
+			http503(res, r)
+		}
+	}
+
+	return negroni.HandlerFunc(fn)
+}
@@ -0,0 +1,61 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+)
+
+// tplBundle combines client templates into one script for prod, adding
+// each source JSON-escaped to a global object keyed like the ids of the
+// inlined script tags.
+func (a *assets) tplBundle(global string) *assetType {
+	return &assetType{
+		name:  tpl.name,
+		ext:   js.ext,
+		html:  js.html,
+		build: func(paths []string) ([]byte, error) { return a.tplScript(global, paths) },
+	}
+}
+
+func (a *assets) tplScript(global string, paths []string) ([]byte, error) {
+	hfs := http.Dir(a.dir)
+	rels, err := a.resolvePaths(paths)
+
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := json.Marshal(global)
+
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBufferString("(function (t) {\n")
+	fmt.Fprintf(buf, "  var g = window[%s] = window[%s] || {};\n", name, name)
+	buf.WriteString("  for (var k in t) g[k] = t[k];\n})({")
+
+	for i, rel := range rels {
+		src := &bytes.Buffer{}
+
+		if err := a.bufferFromPath(hfs, rel, src); err != nil {
+			return nil, err
+		}
+
+		id, _ := json.Marshal(path.Join(a.root, rel))
+		body, _ := json.Marshal(src.String())
+
+		if i > 0 {
+			buf.WriteString(",")
+		}
+
+		fmt.Fprintf(buf, "\n  %s: %s", id, body)
+	}
+
+	buf.WriteString("\n});\n")
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,151 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codegangsta/negroni"
+)
+
+// traceHeader summarizes how a request was handled in debug mode.
+const traceHeader = "X-ABC-Trace"
+
+type traceKey struct{}
+
+// A trace follows a request through the stack: the wares it passed, the
+// template and layout rendered, upstream and render time and fragment
+// cache lookups.
+type trace struct {
+	mu       sync.Mutex
+	wares    []string
+	template string
+	layout   string
+	upstream time.Duration
+	render   time.Duration
+	rendered time.Time
+	cache    []string
+}
+
+type traceWriter struct {
+	ResponseWriter
+	trace *trace
+	wrote bool
+}
+
+func (w *Web) newTrace() Middleware {
+	if !w.config.debug() {
+		return nil
+	}
+
+	fn := func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		t := &trace{}
+		tw := &traceWriter{ResponseWriter: NewResponseWriter(rw), trace: t}
+		next(tw, r.WithContext(context.WithValue(r.Context(), traceKey{}, t)))
+	}
+
+	return negroni.HandlerFunc(fn)
+}
+
+// traced records a ware in the trace of each request passing it.
+func traced(name string, mw Middleware) Middleware {
+	fn := func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		if t := traceFrom(r.Context()); t != nil {
+			t.update(func() { t.wares = append(t.wares, name) })
+		}
+
+		mw.ServeHTTP(rw, r, next)
+	}
+
+	return negroni.HandlerFunc(fn)
+}
+
+func traceFrom(ctx context.Context) *trace {
+	t, _ := ctx.Value(traceKey{}).(*trace)
+	return t
+}
+
+func traceTemplate(ctx context.Context, file string, layout string) {
+	if t := traceFrom(ctx); t != nil {
+		t.update(func() { t.template, t.layout, t.rendered = file, layout, time.Now() })
+	}
+}
+
+func traceCache(ctx context.Context, key string, hit bool) {
+	if t := traceFrom(ctx); t != nil {
+		result := "miss"
+
+		if hit {
+			result = "hit"
+		}
+
+		t.update(func() { t.cache = append(t.cache, key+":"+result) })
+	}
+}
+
+func (t *trace) update(fn func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fn()
+}
+
+// String lists the wares passed, the last of which handled the request,
+// followed by what it did.
+func (t *trace) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	parts := []string{"wares=" + strings.Join(t.wares, ",")}
+
+	if len(t.wares) > 0 {
+		parts = append(parts, "handler="+t.wares[len(t.wares)-1])
+	}
+
+	if t.template != "" {
+		parts = append(parts, "template="+t.template)
+	}
+
+	if t.layout != "" {
+		parts = append(parts, "layout="+t.layout)
+	}
+
+	if t.upstream > 0 {
+		parts = append(parts, fmt.Sprintf("upstream=%s", t.upstream))
+	}
+
+	// The header is written before a render is tracked as done.
+	if t.render == 0 && !t.rendered.IsZero() {
+		t.render = time.Since(t.rendered)
+	}
+
+	if t.render > 0 {
+		parts = append(parts, fmt.Sprintf("render=%s", t.render))
+	}
+
+	if len(t.cache) > 0 {
+		parts = append(parts, "cache="+strings.Join(t.cache, ","))
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+func (tw *traceWriter) WriteHeader(status int) {
+	if !tw.wrote {
+		tw.wrote = true
+		tw.Header().Set(traceHeader, tw.trace.String())
+	}
+
+	tw.ResponseWriter.WriteHeader(status)
+}
+
+func (tw *traceWriter) Write(b []byte) (int, error) {
+	if !tw.wrote {
+		tw.WriteHeader(http.StatusOK)
+	}
+
+	return tw.ResponseWriter.Write(b)
+}
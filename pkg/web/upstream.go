@@ -0,0 +1,170 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sats-group/abc/internal/gateway"
+)
+
+// An Upstream proxies requests under a path prefix to another server.
+// URLs with fastcgi:// or uwsgi:// schemes (or fastcgi+unix:// and
+// uwsgi+unix:// for sockets) use a gateway protocol, where Root is the
+// script root and Index an optional front controller like index.php.
+// MaxInFlight caps concurrent requests to it, with up to MaxQueue more
+// waiting QueueWait for a slot before being shed with a 503.
+type Upstream struct {
+	Path    string
+	URL     string
+	Root    string
+	Index   string
+	Signing *Signing
+
+	MaxInFlight int
+	MaxQueue    int
+	QueueWait   time.Duration
+}
+
+type upstream struct {
+	path     string
+	pool     *pool
+	client   *http.Client
+	signing  *Signing
+	bulkhead *bulkhead
+	gateway  bool
+}
+
+// Headers describing a single connection, not forwarded to gateways.
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+func (c *Config) upstreams() []*upstream {
+	ups := []*upstream{}
+
+	for _, u := range c.Upstreams {
+		ups = append(ups, newUpstream(u))
+	}
+
+	if c.backend() != "" || c.Resolver != nil {
+		ups = append(ups, &upstream{
+			path:     "/",
+			pool:     newPool(c.Balance, c.Sticky, c.backends()),
+			client:   c.Signing.client(),
+			signing:  c.Signing,
+			bulkhead: newBulkhead(c.MaxInFlight, c.MaxQueue, c.QueueWait),
+		})
+	}
+
+	sort.SliceStable(ups, func(i, j int) bool {
+		return len(ups[i].path) > len(ups[j].path)
+	})
+
+	return ups
+}
+
+func newUpstream(u Upstream) *upstream {
+	target, err := url.Parse(u.URL)
+
+	if err != nil {
+		log.Fatalf("invalid upstream: %s (%s)\n", u.URL, err)
+	}
+
+	up := &upstream{
+		path:     "/" + strings.TrimPrefix(u.Path, "/"),
+		pool:     newPool("", "", []string{strings.TrimSuffix(u.URL, "/") + "/"}),
+		client:   u.Signing.client(),
+		signing:  u.Signing,
+		bulkhead: newBulkhead(u.MaxInFlight, u.MaxQueue, u.QueueWait),
+	}
+
+	scheme := strings.TrimSuffix(target.Scheme, "+unix")
+
+	if scheme != gateway.FastCGI && scheme != gateway.UWSGI {
+		return up
+	}
+
+	t := &gateway.Transport{
+		Protocol: scheme,
+		Network:  "tcp",
+		Address:  target.Host,
+		Root:     u.Root,
+		Index:    u.Index,
+	}
+
+	if strings.HasSuffix(target.Scheme, "+unix") {
+		t.Network, t.Address = "unix", target.Path
+	}
+
+	up.pool = newPool("", "", []string{"http://" + scheme + "/"})
+	up.client = &http.Client{Transport: t}
+	up.gateway = true
+
+	return up
+}
+
+// forward passes the client's headers, address and host to a gateway,
+// which hands them to the application as CGI variables.
+func (up *upstream) forward(req *http.Request, r *http.Request) {
+	if !up.gateway {
+		return
+	}
+
+	for key, vals := range r.Header {
+		req.Header[key] = append([]string{}, vals...)
+	}
+
+	for _, key := range hopHeaders {
+		req.Header.Del(key)
+	}
+
+	req.Host = r.Host
+	req.RemoteAddr = r.RemoteAddr
+}
+
+// backendURL picks a backend member for requests made on behalf of r,
+// returning its base URL and a func reporting how the request went.
+func (p *proxy) backendURL(r *http.Request) (string, func(*http.Response, error)) {
+	if up := p.upstream("/"); up != nil && up.path == "/" {
+		if m := up.pool.pick(nil, r); m != nil {
+			return m.base, func(res *http.Response, err error) {
+				m.observe(r, res, err)
+			}
+		}
+	}
+
+	return p.config.backend(), func(*http.Response, error) {}
+}
+
+// observe reports how a request to the member went, unless it ended
+// because its client left or a deadline of ours passed, which says
+// nothing about the member.
+func (m *member) observe(r *http.Request, res *http.Response, err error) {
+	if r.Context().Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+
+	m.report(upstreamOK(res, err))
+}
+
+// upstreamOK reports if a response shows its upstream to be working.
+func upstreamOK(res *http.Response, err error) bool {
+	return err == nil && res.StatusCode != http.StatusBadGateway && res.StatusCode != http.StatusServiceUnavailable
+}
+
+func (p *proxy) upstream(path string) *upstream {
+	for _, u := range p.upstreams {
+		if strings.HasPrefix(path, u.path) {
+			return u
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package web
+
+import (
+	"bytes"
+	"encoding/base64"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var cssURL = regexp.MustCompile(`url\(\s*(['"]?)([^'")]+)(['"]?)\s*\)`)
+
+// rewriteURLs points relative url() references in a stylesheet at their
+// public path, since the bundle is served from elsewhere. Files up to the
+// inline limit become data URIs instead.
+func (a *assets) rewriteURLs(dir http.FileSystem, rel string, b []byte) []byte {
+	base := path.Dir(rel)
+
+	return cssURL.ReplaceAllFunc(b, func(m []byte) []byte {
+		parts := cssURL.FindSubmatch(m)
+		target := strings.TrimSpace(string(parts[2]))
+
+		if !relativeURL(target) {
+			return m
+		}
+
+		if data, ok := a.dataURI(dir, path.Join(base, target)); ok {
+			return []byte(`url("` + data + `")`)
+		}
+
+		return []byte("url(" + string(parts[1]) + path.Join("/", a.root, base, target) + string(parts[3]) + ")")
+	})
+}
+
+func (a *assets) dataURI(dir http.FileSystem, rel string) (string, bool) {
+	if a.inlineLimit <= 0 || strings.ContainsAny(rel, "?#") {
+		return "", false
+	}
+
+	f, err := dir.Open(rel)
+
+	if err != nil {
+		return "", false
+	}
+
+	defer f.Close()
+
+	info, err := f.Stat()
+
+	if err != nil || info.IsDir() || info.Size() > a.inlineLimit {
+		return "", false
+	}
+
+	buf := &bytes.Buffer{}
+
+	if _, err := buf.ReadFrom(f); err != nil {
+		return "", false
+	}
+
+	kind := mime.TypeByExtension(path.Ext(rel))
+
+	if kind == "" {
+		kind = "application/octet-stream"
+	}
+
+	return "data:" + kind + ";base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), true
+}
@@ -0,0 +1,188 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const validateTimeout = 5 * time.Second
+
+// Problems lists everything Validate found wrong.
+type Problems []error
+
+func (p Problems) Error() string {
+	lines := make([]string, len(p))
+
+	for i, err := range p {
+		lines[i] = err.Error()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Validate checks the config, compiles all templates, loads JSON data,
+// renders pages to build asset bundles and pings the backends, without
+// binding the port. It reports every problem found as Problems. Pages
+// only render once the config and templates are valid.
+func (w *Web) Validate() error {
+	problems := Problems{}
+	problems = append(problems, w.config.validate()...)
+	problems = append(problems, w.engine.validate(len(problems) == 0)...)
+
+	if w.engine.candidate != nil {
+		problems = append(problems, w.engine.candidate.validate(len(problems) == 0)...)
+	}
+
+	problems = append(problems, w.validateBackends()...)
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return problems
+}
+
+// Validate checks a config like Web.Validate, creating the server only
+// once the config is valid, since New stops the process on some invalid
+// settings. Config problems are all reported together.
+func Validate(opts ...Option) error {
+	c := newConfig(opts)
+
+	if problems := Problems(c.validate()); len(problems) > 0 {
+		return problems
+	}
+
+	return New(c).Validate()
+}
+
+func (c *Config) validate() []error {
+	errs := []error{}
+
+	if c.Frontend != "" {
+		errs = appendErr(errs, c.validateAddr("frontend", c.Frontend))
+	}
+
+	if _, err := c.port(); err != nil {
+		errs = append(errs, fmt.Errorf("invalid frontend port: %s (%s)", c.frontend(), err))
+	}
+
+	if c.Backend != "" {
+		errs = appendErr(errs, c.validateAddr("backend", c.Backend))
+	}
+
+	for _, b := range c.Backends {
+		errs = appendErr(errs, c.validateAddr("backend", b))
+	}
+
+	for _, pattern := range c.Auth {
+		if pattern != "" {
+			_, _, _, err := splitAuthPattern(pattern)
+			errs = appendErr(errs, err)
+		}
+	}
+
+	for _, cidr := range append(append([]string{}, c.AuthTrust...), c.AuthProxy...) {
+		_, err := parseNetwork(cidr)
+		errs = appendErr(errs, err)
+	}
+
+	for _, rel := range c.JSON {
+		_, err := c.loadE(rel)
+		errs = appendErr(errs, err)
+	}
+
+	return errs
+}
+
+func (c *Config) validateAddr(name string, addr string) error {
+	if strings.HasPrefix(addr, ":") {
+		addr = "http://localhost" + addr
+	}
+
+	if err := c.addrValidate(addr); err != nil {
+		return fmt.Errorf("invalid %s: %s", name, strings.TrimSpace(err.Error()))
+	}
+
+	return nil
+}
+
+func (e *engine) validate(render bool) []error {
+	errs := []error{}
+	pages := []string{}
+
+	e.mu.RLock()
+	funcs := e.funcs
+	e.mu.RUnlock()
+
+	err := e.walkTemplates(func(dir string, rel string) error {
+		if e.config.Renderer == nil {
+			root := template.New(e.config.dir()).Funcs(funcs)
+			errs = appendErr(errs, e.compileTemplate(root, dir, rel))
+		}
+
+		if dir == e.config.dir() && filepath.Ext(rel) == e.config.frontendExt() && !isPartial(rel) {
+			pages = append(pages, rel)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return append(errs, err)
+	}
+
+	if e.config.Renderer != nil {
+		_, err := e.compileTemplates()
+		errs = appendErr(errs, err)
+	}
+
+	if len(errs) > 0 || !render {
+		return errs
+	}
+
+	// Pages and layouts render bare, so a layout never yields to itself.
+	env := Env{"prod": e.config.prod(), "config": e.config.json(), layoutKey: noLayout}
+
+	for _, rel := range pages {
+		if _, err := e.execute(rel, env); err != nil {
+			errs = append(errs, fmt.Errorf("render error: %s (%s)", rel, err))
+		}
+	}
+
+	return errs
+}
+
+func (w *Web) validateBackends() []error {
+	errs := []error{}
+	client := &http.Client{Timeout: validateTimeout}
+
+	for _, base := range w.config.backends() {
+		req, err := http.NewRequest(http.MethodHead, base, nil)
+
+		if err == nil {
+			var res *http.Response
+
+			if res, err = client.Do(req); err == nil {
+				res.Body.Close()
+			}
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("backend unreachable: %s (%s)", base, err))
+		}
+	}
+
+	return errs
+}
+
+func appendErr(errs []error, err error) []error {
+	if err == nil {
+		return errs
+	}
+
+	return append(errs, err)
+}
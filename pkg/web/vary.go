@@ -0,0 +1,106 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type variantKey struct{}
+
+// variant describes what a page may vary on for a request: the
+// negotiated language, experiment cookies, the template set and
+// whether it is authenticated. Cached renders are keyed by it.
+func (c *Config) variant(r *http.Request) string {
+	parts := []string{}
+
+	if len(c.Languages) > 0 {
+		parts = append(parts, "lang="+c.language(r))
+	}
+
+	for _, name := range c.Variants {
+		if cookie, err := r.Cookie(name); err == nil {
+			parts = append(parts, name+"="+cookie.Value)
+		}
+	}
+
+	if isCandidate(r.Context()) {
+		parts = append(parts, candidateSet)
+	}
+
+	if r.Header.Get("Authorization") != "" {
+		parts = append(parts, "auth")
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// language negotiates one of the supported languages from the
+// Accept-Language header, falling back to the first.
+func (c *Config) language(r *http.Request) string {
+	type accept struct {
+		tag string
+		q   float64
+	}
+
+	accepts := []accept{}
+
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		a := accept{tag: strings.ToLower(fields[0]), q: 1}
+
+		for _, f := range fields[1:] {
+			if v := strings.TrimPrefix(strings.TrimSpace(f), "q="); v != f {
+				a.q, _ = strconv.ParseFloat(v, 64)
+			}
+		}
+
+		if a.tag != "" && a.q > 0 {
+			accepts = append(accepts, a)
+		}
+	}
+
+	sort.SliceStable(accepts, func(i, j int) bool { return accepts[i].q > accepts[j].q })
+
+	for _, a := range accepts {
+		for _, lang := range c.Languages {
+			l := strings.ToLower(lang)
+
+			if a.tag == l || strings.SplitN(a.tag, "-", 2)[0] == strings.SplitN(l, "-", 2)[0] {
+				return lang
+			}
+		}
+	}
+
+	return c.Languages[0]
+}
+
+// vary lists the request headers a page may vary on.
+func (c *Config) vary(r *http.Request) []string {
+	keys := []string{}
+
+	if len(c.Languages) > 0 {
+		keys = append(keys, "Accept-Language")
+	}
+
+	if len(c.Variants) > 0 || c.Candidate != "" {
+		keys = append(keys, "Cookie")
+	}
+
+	if len(c.Auth) > 0 || r.Header.Get("Authorization") != "" {
+		keys = append(keys, "Authorization")
+	}
+
+	return keys
+}
+
+func withVariant(ctx context.Context, variant string) context.Context {
+	return context.WithValue(ctx, variantKey{}, variant)
+}
+
+func variantFrom(ctx context.Context) string {
+	v, _ := ctx.Value(variantKey{}).(string)
+	return v
+}
@@ -0,0 +1,64 @@
+package web
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sats-group/abc/internal/files"
+)
+
+// assetInputs are the extensions of files bundles are built from.
+var assetInputs = map[string]bool{
+	css.ext: true,
+	js.ext:  true,
+	".mjs":  true,
+	md.ext:  true,
+	".html": true,
+}
+
+// assetVersion names the asset prefix of a build: the configured
+// AssetVersion, or else a hash of the site's asset inputs outside the
+// asset cache, so instances of the same build share asset URLs.
+func (c *Config) assetVersion() string {
+	if c.AssetVersion != "" {
+		return hash(c.AssetVersion)
+	}
+
+	h := sha1.New()
+	cache, _ := filepath.Rel(c.dir(), c.AssetCache)
+
+	err := files.Walk(c.dir(), func(rel string) error {
+		if files.Excluded(rel) || !assetInputs[strings.ToLower(filepath.Ext(rel))] {
+			return nil
+		}
+
+		if c.AssetCache != "" && (rel == cache || strings.HasPrefix(rel, cache+string(filepath.Separator))) {
+			return nil
+		}
+
+		f, err := os.Open(filepath.Join(c.dir(), rel))
+
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		fmt.Fprintf(h, "%s\x00", filepath.ToSlash(rel))
+		_, err = io.Copy(h, f)
+
+		return err
+	})
+
+	if err != nil {
+		log.Println("asset version: using a timestamp, as hashing assets failed:", err)
+		return hash(timestamp())
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil))[:12]
+}
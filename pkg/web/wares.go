@@ -1,9 +1,8 @@
 package web
 
 import (
-	"log"
+	"context"
 	"net/http"
-	"os"
 	"path"
 	"strings"
 
@@ -16,16 +15,21 @@ import (
 func (w *Web) newRecover() Middleware {
 	rec := negroni.NewRecovery()
 	rec.PrintStack = !w.config.Prod
-	rec.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	rec.Logger = w.errorLog()
 	return rec
 }
 
+// peerKey keeps the address of the connection's peer, before RealIP
+// replaces it with the client address claimed by forwarding headers.
+type peerKey struct{}
+
 func (w *Web) newReverse() Middleware {
 	if !w.config.Proxy {
 		return nil
 	}
 
 	fn := func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		r = r.WithContext(context.WithValue(r.Context(), peerKey{}, r.RemoteAddr))
 		middleware.RealIP(next).ServeHTTP(rw, r)
 	}
 
@@ -88,20 +92,32 @@ func (w *Web) newIgnore() Middleware {
 }
 
 func (w *Web) newStatic() Middleware {
-	return negroni.NewStatic(http.Dir(w.config.dir()))
-}
+	static := negroni.NewStatic(http.Dir(w.config.dir()))
 
-func (w *Web) newNocache() Middleware {
 	fn := func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-		middleware.NoCache(next).ServeHTTP(rw, r)
+		if w.config.staticDenied(r.URL.Path) {
+			next(rw, r)
+			return
+		}
+
+		static.ServeHTTP(rw, r, next)
 	}
 
 	return negroni.HandlerFunc(fn)
 }
 
-func (w *Web) newNotfound() Middleware {
+func (w *Web) newNocache() Middleware {
 	fn := func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-		http404(rw, r)
+		match := r.Header.Get("If-None-Match")
+
+		// Keep the client's ETags for decorated pages with a caching policy.
+		middleware.NoCache(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if match != "" {
+				r.Header.Set("If-None-Match", match)
+			}
+
+			next(rw, r)
+		})).ServeHTTP(rw, r)
 	}
 
 	return negroni.HandlerFunc(fn)
@@ -3,10 +3,18 @@ package web
 
 import (
 	"bytes"
+	"context"
 	"html/template"
+	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/codegangsta/negroni"
+	"github.com/sats-group/abc/internal/files"
 )
 
 // An Env contains data for a template.
@@ -20,6 +28,11 @@ type Handler interface {
 // The HandlerFunc type lets functions be Handlers.
 type HandlerFunc func(rw http.ResponseWriter, r *http.Request, p Params)
 
+// ServeHTTP calls f(rw, r, p).
+func (f HandlerFunc) ServeHTTP(rw http.ResponseWriter, r *http.Request, p Params) {
+	f(rw, r, p)
+}
+
 // Middleware are bidirectonal wrappers around requests.
 type Middleware interface {
 	ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc)
@@ -30,32 +43,55 @@ type MiddlewareFunc func(rw http.ResponseWriter, r *http.Request, next http.Hand
 
 // A Web server is a stack of middleware and a router.
 type Web struct {
-	config *Config
-	router *router
-	engine *engine
-	assets *assets
-	before []Middleware
-	after  []Middleware
-}
-
-// New creates a server instance.
-func New(c *Config) *Web {
-	if c == nil {
-		c = &Config{}
-	}
+	config  *Config
+	stats   *counters
+	store   CacheStore
+	router  Router
+	engine  *engine
+	assets  *assets
+	icons   *icons
+	svgs    *svgIcons
+	cookies *cookies
+	proxy   *proxy
+	grpc    *transcoder
+	jobs    *jobs
+	hooks   lifecycle
+	server  *http.Server
+	stopped chan error
+	logs    logOutputs
+	routes  []string
+	known   pathIndex
+	wares   []ware
+}
 
+// New creates a server instance from a Config, options or both.
+func New(opts ...Option) *Web {
+	c := newConfig(opts)
 	w := &Web{config: c}
 
+	if err := files.LoadIgnore(c.dir()); err != nil {
+		log.Fatalln(err)
+	}
+
+	w.jobs = w.newJobs()
+	w.newLogOutputs()
+	w.stats = w.newStats()
+	w.store = w.newStore()
 	w.router = w.newRouter()
+	w.cookies = w.newCookies()
 	w.engine = w.newEngine()
 	w.assets = w.newAssets()
-	w.before = w.newBefore()
-	w.after = w.newAfter()
+	w.icons = w.newIcons()
+	w.svgs = w.newSVGs()
+	w.proxy = w.newProxy()
+	w.newPurge()
+	w.wares = w.newWares()
 
 	return w
 }
 
-// Serve starts the server at the frontend URL.
+// Serve starts the server at the frontend URL, shutting it down
+// gracefully on SIGINT or SIGTERM.
 func (w *Web) Serve() error {
 	port, err := w.config.port()
 
@@ -63,7 +99,79 @@ func (w *Web) Serve() error {
 		return err
 	}
 
-	return http.ListenAndServe(port, w.newStack())
+	if w.config.Audit {
+		for _, risk := range w.SecurityAudit() {
+			log.Println("security:", risk)
+		}
+	}
+
+	if err := w.hooks.started(); err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", port)
+
+	if err != nil {
+		return err
+	}
+
+	w.server = &http.Server{Addr: port, Handler: w.newStack(), ErrorLog: w.errorLog()}
+	w.stopped = make(chan error, 1)
+	quit := make(chan struct{})
+	defer close(quit)
+
+	go w.hooks.readied()
+
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(sig)
+
+		select {
+		case <-sig:
+		case <-quit:
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), w.config.shutdownTimeout())
+		defer cancel()
+
+		_ = w.Shutdown(ctx)
+	}()
+
+	if err := w.server.Serve(ln); err != http.ErrServerClosed {
+		return err
+	}
+
+	return <-w.stopped
+}
+
+// Shutdown stops background jobs and, when started by Serve, the server,
+// waiting for running jobs and requests until ctx is done, then runs
+// the shutdown hooks. Serve returns the same result.
+func (w *Web) Shutdown(ctx context.Context) error {
+	var err error
+
+	if w.server != nil {
+		err = w.server.Shutdown(ctx)
+	}
+
+	if jerr := w.jobs.stop(ctx); err == nil {
+		err = jerr
+	}
+
+	if herr := w.hooks.stopped(ctx); err == nil {
+		err = herr
+	}
+
+	if w.stopped != nil {
+		select {
+		case w.stopped <- err:
+		default:
+		}
+	}
+
+	return err
 }
 
 // ServeHTTP handles a given req/res (used for testing).
@@ -73,23 +181,29 @@ func (w *Web) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 
 // Handler adds a handler object for the given method and path.
 func (w *Web) Handler(method string, path string, handler Handler) {
-	w.router.handler(method, path, handler)
+	method = strings.ToUpper(method)
+
+	if method == http.MethodGet && !strings.ContainsAny(path, ":*") {
+		w.routes = append(w.routes, path)
+	}
+
+	w.router.Handle(method, path, handler)
 }
 
 // HandlerFunc adds a handler func for the given method and path.
 func (w *Web) HandlerFunc(method string, path string, handler HandlerFunc) {
-	w.router.handlerFunc(method, path, handler)
+	w.Handler(method, path, handler)
 }
 
 // Middleware adds a ware object to the stack, pre router.
 func (w *Web) Middleware(mw ...Middleware) {
-	w.before = append(w.before, mw...)
+	w.insert(w.index(routerWare), mw)
 }
 
 // MiddlewareFunc adds a ware func to the stack, pre router.
 func (w *Web) MiddlewareFunc(mw ...MiddlewareFunc) {
 	for _, m := range mw {
-		w.before = append(w.before, negroni.HandlerFunc(m))
+		w.Middleware(negroni.HandlerFunc(m))
 	}
 }
 
@@ -98,6 +212,17 @@ func (w *Web) Execute(file string, input Env) (*bytes.Buffer, error) {
 	return w.engine.execute(file, input)
 }
 
+// ExecuteContext renders a template file with data until ctx is done.
+func (w *Web) ExecuteContext(ctx context.Context, file string, input Env) (*bytes.Buffer, error) {
+	return w.engine.executeContext(ctx, file, input, nil)
+}
+
+// ExecuteFuncs renders a template file with data, overriding funcs
+// added with FuncMap for this render only.
+func (w *Web) ExecuteFuncs(file string, input Env, funcs template.FuncMap) (*bytes.Buffer, error) {
+	return w.engine.executeFuncs(file, input, funcs)
+}
+
 // Respond renders a template file with data as a response.
 func (w *Web) Respond(rw http.ResponseWriter, r *http.Request, status int, file string, input Env) {
 	w.engine.respond(rw, r, status, file, input)
@@ -112,8 +237,13 @@ func (w *Web) Redirect(from, to string, code int) {
 
 // NotFound adds a fallback handler for unmatched requests.
 func (w *Web) NotFound(handler http.HandlerFunc) {
-	w.after = w.after[:len(w.after)-1]
-	w.after = append(w.after, newMiddleware(handler))
+	w.wares[w.index(notfoundWare)].mw = newMiddleware(handler)
+}
+
+// Ignore adds gitignore-style patterns for files that are never served,
+// rendered or bundled.
+func (w *Web) Ignore(patterns ...string) {
+	files.AddIgnore(patterns...)
 }
 
 // FuncMap adds to the map of template functions.
@@ -121,51 +251,69 @@ func (w *Web) FuncMap(funcs template.FuncMap) {
 	w.engine.funcMap(funcs)
 }
 
-func (w *Web) newBefore() []Middleware {
-	return []Middleware{
-		w.newRecover(),
-		w.newReverse(),
-		w.newPrefix(),
-		w.newSecure(),
-		w.newIgnore(),
-		w.newAuth(),
-	}
+// EnvFunc adds a func providing data for every render of a request,
+// including auto-rendered pages and decorated responses. Data passed to
+// the render takes precedence.
+func (w *Web) EnvFunc(fn func(*http.Request) Env) {
+	w.engine.envFunc(fn)
 }
 
-func (w *Web) newAfter() []Middleware {
-	if w.config.prod() {
-		return []Middleware{
-			w.engine,
-			w.newStatic(),
-			w.newNocache(),
-			w.newProxy(),
-			w.newNotfound(),
-		}
+func (w *Web) newWares() []ware {
+	wares := []ware{
+		{"writer", w.newWriter()},
+		{"trace", w.newTrace()},
+		{"log", w.newLogger()},
+		{"deadline", w.newDeadline()},
+		{"recover", w.newRecover()},
+		{"reverse", w.newReverse()},
+		{"prefix", w.newPrefix()},
+		{"amp", w.newAMP()},
+		{"secure", w.newSecure()},
+		{"nonce", w.newNonce()},
+		{"headers", w.newHeaders()},
+		{"robots", w.newRobots()},
+		{"ignore", w.newIgnore()},
+		{"auth", w.newAuth()},
+		{"bodies", w.newBodies()},
+		{"candidate", w.newCandidateSet()},
+		{routerWare, w.router},
+		{"routes", w.newRoutes()},
 	}
 
-	return []Middleware{
-		w.newNocache(),
-		w.engine,
-		w.newStatic(),
-		w.newProxy(),
-		w.newNotfound(),
+	if w.config.prod() {
+		return append(wares,
+			ware{"engine", w.engine},
+			ware{"static", w.newStatic()},
+			ware{"index", w.newIndex()},
+			ware{"nocache", w.newNocache()},
+			ware{"proxy", w.proxyMiddleware()},
+			ware{notfoundWare, w.newNotfound()},
+		)
 	}
+
+	return append(wares,
+		ware{"nocache", w.newNocache()},
+		ware{"devserver", w.newDevServer()},
+		ware{"engine", w.engine},
+		ware{"static", w.newStatic()},
+		ware{"index", w.newIndex()},
+		ware{"proxy", w.proxyMiddleware()},
+		ware{notfoundWare, w.newNotfound()},
+	)
 }
 
 func (w *Web) newStack() http.Handler {
 	stack := negroni.New()
 
-	w.append(stack, w.before...)
-	w.append(stack, w.router)
-	w.append(stack, w.after...)
-
-	return stack
-}
-
-func (w *Web) append(stack *negroni.Negroni, mw ...Middleware) {
-	for _, handler := range mw {
-		if handler != nil {
-			stack.Use(handler)
+	for _, ware := range w.wares {
+		switch {
+		case ware.mw == nil:
+		case w.config.debug():
+			stack.Use(traced(ware.name, ware.mw))
+		default:
+			stack.Use(ware.mw)
 		}
 	}
+
+	return stack
 }
@@ -0,0 +1,236 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhook signature schemes.
+const (
+	WebhookHMAC   = "hmac"
+	WebhookGitHub = "github"
+	WebhookStripe = "stripe"
+)
+
+// A Webhook verifies deliveries to a webhook endpoint. Scheme selects
+// how the HMAC-SHA256 signature over the body with Secret is sent: in
+// Header as hex (WebhookHMAC, the default), as X-Hub-Signature-256
+// (WebhookGitHub) or as Stripe-Signature with a timestamp
+// (WebhookStripe). Limit caps body sizes and Queue the deliveries
+// waiting for the callback.
+type Webhook struct {
+	Secret    string
+	Scheme    string
+	Header    string
+	Limit     int64
+	Queue     int
+	Tolerance time.Duration
+}
+
+// A WebhookEvent is a verified delivery handed to a webhook callback.
+type WebhookEvent struct {
+	Path     string
+	Header   http.Header
+	Body     []byte
+	Received time.Time
+}
+
+// Webhook adds a POST route receiving webhooks. Verified deliveries are
+// accepted right away and passed to fn one at a time in the background.
+// Shutdown waits for accepted deliveries until its deadline.
+func (w *Web) Webhook(path string, hook Webhook, fn func(WebhookEvent) error) {
+	if hook.Secret == "" {
+		log.Fatalf("webhook requires a secret: %s\n", path)
+	}
+
+	queue := make(chan WebhookEvent, hook.queue())
+
+	j := w.jobs
+	j.wg.Add(1)
+
+	go func() {
+		defer j.wg.Done()
+
+		for {
+			select {
+			case <-j.ctx.Done():
+				drain(queue, fn)
+				return
+			case event := <-queue:
+				deliver(event, fn)
+			}
+		}
+	}()
+
+	w.HandlerFunc("post", path, func(rw http.ResponseWriter, r *http.Request, _ Params) {
+		if r.ContentLength > hook.limit() {
+			http413(rw, r)
+			return
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(rw, r.Body, hook.limit()))
+
+		if err != nil {
+			http413(rw, r)
+			return
+		}
+
+		if !hook.verify(r.Header, body) {
+			http401(rw, r)
+			return
+		}
+
+		if j.ctx.Err() != nil {
+			http503(rw, r)
+			return
+		}
+
+		select {
+		case queue <- WebhookEvent{Path: r.URL.Path, Header: r.Header, Body: body, Received: time.Now()}:
+			rw.WriteHeader(http.StatusAccepted)
+		default:
+			http503(rw, r)
+		}
+	})
+}
+
+// drain delivers the events left in a queue on shutdown.
+func drain(queue chan WebhookEvent, fn func(WebhookEvent) error) {
+	for {
+		select {
+		case event := <-queue:
+			deliver(event, fn)
+		default:
+			return
+		}
+	}
+}
+
+// deliver passes an event to the callback, logging errors and panics.
+func deliver(event WebhookEvent, fn func(WebhookEvent) error) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("webhook panic: %s %s\n%s", event.Path, err, debug.Stack())
+		}
+	}()
+
+	if err := fn(event); err != nil {
+		log.Println("webhook:", event.Path, err)
+	}
+}
+
+func (h *Webhook) limit() int64 {
+	if h.Limit <= 0 {
+		return 1 << 20
+	}
+
+	return h.Limit
+}
+
+func (h *Webhook) queue() int {
+	if h.Queue <= 0 {
+		return 100
+	}
+
+	return h.Queue
+}
+
+func (h *Webhook) tolerance() time.Duration {
+	if h.Tolerance <= 0 {
+		return 5 * time.Minute
+	}
+
+	return h.Tolerance
+}
+
+func (h *Webhook) header() string {
+	if h.Header != "" {
+		return h.Header
+	}
+
+	switch h.Scheme {
+	case WebhookGitHub:
+		return "X-Hub-Signature-256"
+	case WebhookStripe:
+		return "Stripe-Signature"
+	}
+
+	return "X-Signature"
+}
+
+func (h *Webhook) verify(header http.Header, body []byte) bool {
+	sig := header.Get(h.header())
+
+	if sig == "" {
+		return false
+	}
+
+	if h.Scheme == WebhookStripe {
+		return h.verifyStripe(sig, body)
+	}
+
+	return h.matches(strings.TrimPrefix(sig, "sha256="), body)
+}
+
+// verifyStripe checks a "t=<unix>,v1=<hex>" header, signed over the
+// timestamp and body, rejecting stale timestamps against replays.
+func (h *Webhook) verifyStripe(sig string, body []byte) bool {
+	var stamp string
+	var sums []string
+
+	for _, part := range strings.Split(sig, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch kv[0] {
+		case "t":
+			stamp = kv[1]
+		case "v1":
+			sums = append(sums, kv[1])
+		}
+	}
+
+	unix, err := strconv.ParseInt(stamp, 10, 64)
+
+	if err != nil {
+		return false
+	}
+
+	if age := time.Since(time.Unix(unix, 0)); age > h.tolerance() || age < -h.tolerance() {
+		return false
+	}
+
+	signed := append([]byte(stamp+"."), body...)
+
+	for _, sum := range sums {
+		if h.matches(sum, signed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h *Webhook) matches(sum string, payload []byte) bool {
+	got, err := hex.DecodeString(sum)
+
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(h.Secret))
+	mac.Write(payload)
+
+	return hmac.Equal(got, mac.Sum(nil))
+}
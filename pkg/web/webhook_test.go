@@ -0,0 +1,57 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWebhookVerify(t *testing.T) {
+	body := []byte(`{"event":"push"}`)
+	now := time.Now().Unix()
+	stripe := func(at int64, secret string) string {
+		return fmt.Sprintf("t=%d,v1=%s", at, signHex(secret, []byte(fmt.Sprintf("%d.%s", at, body))))
+	}
+
+	tests := []struct {
+		name   string
+		hook   Webhook
+		header http.Header
+		want   bool
+	}{
+		{"hmac", Webhook{}, http.Header{"X-Signature": {signHex("secret", body)}}, true},
+		{"hmac prefixed", Webhook{}, http.Header{"X-Signature": {"sha256=" + signHex("secret", body)}}, true},
+		{"hmac other secret", Webhook{}, http.Header{"X-Signature": {signHex("other", body)}}, false},
+		{"hmac not hex", Webhook{}, http.Header{"X-Signature": {"zz"}}, false},
+		{"hmac unsigned", Webhook{}, http.Header{}, false},
+		{"hmac header", Webhook{Header: "X-Sig"}, http.Header{"X-Sig": {signHex("secret", body)}}, true},
+		{"github", Webhook{Scheme: WebhookGitHub}, http.Header{"X-Hub-Signature-256": {"sha256=" + signHex("secret", body)}}, true},
+		{"stripe", Webhook{Scheme: WebhookStripe}, http.Header{"Stripe-Signature": {stripe(now, "secret")}}, true},
+		{"stripe rolled secret", Webhook{Scheme: WebhookStripe}, http.Header{"Stripe-Signature": {stripe(now, "old") + ",v1=" + signHex("secret", []byte(fmt.Sprintf("%d.%s", now, body)))}}, true},
+		{"stripe body only", Webhook{Scheme: WebhookStripe}, http.Header{"Stripe-Signature": {fmt.Sprintf("t=%d,v1=%s", now, signHex("secret", body))}}, false},
+		{"stripe stale", Webhook{Scheme: WebhookStripe}, http.Header{"Stripe-Signature": {stripe(now-600, "secret")}}, false},
+		{"stripe future", Webhook{Scheme: WebhookStripe}, http.Header{"Stripe-Signature": {stripe(now+600, "secret")}}, false},
+		{"stripe tolerance", Webhook{Scheme: WebhookStripe, Tolerance: time.Hour}, http.Header{"Stripe-Signature": {stripe(now-600, "secret")}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.hook.Secret = "secret"
+
+			if got := tt.hook.verify(tt.header, body); got != tt.want {
+				t.Errorf("verify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func signHex(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
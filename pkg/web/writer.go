@@ -0,0 +1,107 @@
+package web
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/codegangsta/negroni"
+)
+
+// A ResponseWriter records the status and size of a response. Every
+// middleware in the stack gets one, so logging, metrics and transforms
+// can read them without a wrapper of their own. Flush, Hijack and Push
+// pass through to the underlying writer where it supports them.
+type ResponseWriter interface {
+	http.ResponseWriter
+	http.Flusher
+	http.Hijacker
+	http.Pusher
+
+	// Status is the status written, or 0 before the header is written.
+	Status() int
+
+	// BytesWritten is the size of the body written so far.
+	BytesWritten() int
+
+	// Unwrap returns the underlying writer.
+	Unwrap() http.ResponseWriter
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+// NewResponseWriter wraps a writer to record its status and size, unless
+// it is a ResponseWriter already.
+func NewResponseWriter(rw http.ResponseWriter) ResponseWriter {
+	if w, ok := rw.(ResponseWriter); ok {
+		return w
+	}
+
+	return &responseWriter{ResponseWriter: rw}
+}
+
+func (w *Web) newWriter() Middleware {
+	fn := func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		next(NewResponseWriter(rw), r)
+	}
+
+	return negroni.HandlerFunc(fn)
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+
+	n, err := rw.ResponseWriter.Write(b)
+	rw.size += n
+
+	return n, err
+}
+
+func (rw *responseWriter) Status() int {
+	return rw.status
+}
+
+func (rw *responseWriter) BytesWritten() int {
+	return rw.size
+}
+
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := rw.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+
+	return nil, nil, errors.New("response writer does not support hijacking")
+}
+
+func (rw *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := rw.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+
+	return http.ErrNotSupported
+}